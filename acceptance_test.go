@@ -0,0 +1,157 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pinecone
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/conduitio/conduit-commons/config"
+	"github.com/conduitio/conduit-commons/opencdc"
+	sdk "github.com/conduitio/conduit-connector-sdk"
+	"github.com/google/uuid"
+	"github.com/matryer/is"
+	"go.uber.org/goleak"
+)
+
+// acceptanceTestDriver writes and reads vector records, given that the
+// destination only accepts payloads with the pineconeVectorValues shape. Each
+// acceptance test runs in its own namespace.
+type acceptanceTestDriver struct {
+	sdk.ConfigurableAcceptanceTestDriver
+
+	// runID makes the namespaces unique across test runs against a real index.
+	runID    string
+	stateDir string
+}
+
+var nonNamespaceChars = regexp.MustCompile(`[^a-zA-Z0-9-]+`)
+
+func (d acceptanceTestDriver) namespace(t *testing.T) string {
+	return fmt.Sprintf("acceptance-%s-%s", d.runID, nonNamespaceChars.ReplaceAllString(t.Name(), "-"))
+}
+
+func (d acceptanceTestDriver) SourceConfig(t *testing.T) config.Config {
+	cfg := srcConfigFromEnv(t, d.namespace(t))
+	cfg.PollingPeriod = 100 * time.Millisecond
+	cfg.StateFile = filepath.Join(d.stateDir, d.namespace(t)+".json")
+
+	return cfg.toMap()
+}
+
+func (d acceptanceTestDriver) DestinationConfig(t *testing.T) config.Config {
+	cfg := destConfigFromEnv(t)
+	cfg.Namespace = d.namespace(t)
+
+	return cfg.toMap()
+}
+
+func (d acceptanceTestDriver) AfterTest(t *testing.T) {
+	cfg := destConfigFromEnv(t)
+	cfg.Namespace = d.namespace(t)
+
+	index := createIndex(is.New(t), cfg)
+	defer index.Close()
+
+	deleteAllRecords(is.New(t), index)
+}
+
+func (d acceptanceTestDriver) GenerateRecord(t *testing.T, op opencdc.Operation) opencdc.Record {
+	is := is.New(t)
+
+	payload, err := json.Marshal(pineconeVectorValues{
+		Values: []float32{rand.Float32(), rand.Float32()},
+		SparseValues: &sparseValues{
+			Indices: []uint32{1, 3},
+			Values:  []float32{rand.Float32(), rand.Float32()},
+		},
+	})
+	is.NoErr(err)
+
+	return opencdc.Record{
+		Position:  opencdc.Position(uuid.NewString()),
+		Operation: op,
+		Metadata:  opencdc.Metadata{randString(): randString()},
+		Key:       opencdc.RawData(uuid.NewString()),
+		Payload: opencdc.Change{
+			After: opencdc.RawData(payload),
+		},
+	}
+}
+
+// WriteToSource writes the records with the destination, configured to write
+// into the namespace of the test.
+func (d acceptanceTestDriver) WriteToSource(t *testing.T, records []opencdc.Record) []opencdc.Record {
+	writeTestRecords(context.Background(), t, is.New(t), d.namespace(t), records)
+	return records
+}
+
+// ReadFromDestination fetches the written vectors straight from the index.
+func (d acceptanceTestDriver) ReadFromDestination(t *testing.T, records []opencdc.Record) []opencdc.Record {
+	is := is.New(t)
+	ctx := context.Background()
+
+	cfg := destConfigFromEnv(t)
+	cfg.Namespace = d.namespace(t)
+
+	index := createIndex(is, cfg)
+	defer index.Close()
+
+	ids := make([]string, len(records))
+	for i, rec := range records {
+		ids[i] = string(rec.Key.Bytes())
+	}
+
+	res, err := index.FetchVectors(ctx, ids)
+	is.NoErr(err)
+
+	got := make([]opencdc.Record, 0, len(records))
+	for _, rec := range records {
+		vec, ok := res.Vectors[string(rec.Key.Bytes())]
+		if !ok {
+			continue
+		}
+
+		gotRec, err := vectorToRecord(vec, cfg.Namespace, rec.Operation, sourcePosition{})
+		is.NoErr(err)
+
+		got = append(got, gotRec)
+	}
+
+	return got
+}
+
+func TestAcceptance(t *testing.T) {
+	sdk.AcceptanceTest(t, acceptanceTestDriver{
+		ConfigurableAcceptanceTestDriver: sdk.ConfigurableAcceptanceTestDriver{
+			Config: sdk.ConfigurableAcceptanceTestDriverConfig{
+				Connector: Connector,
+				GoleakOptions: []goleak.Option{
+					// the fake Pinecone server is shared by all tests
+					goleak.IgnoreCurrent(),
+					goleak.IgnoreAnyFunction("google.golang.org/grpc/internal/grpcsync.(*CallbackSerializer).run"),
+				},
+			},
+		},
+		runID:    randString(),
+		stateDir: t.TempDir(),
+	})
+}
@@ -25,58 +25,413 @@ import (
 	sdk "github.com/conduitio/conduit-connector-sdk"
 	cmap "github.com/orcaman/concurrent-map/v2"
 	"github.com/pinecone-io/go-pinecone/pinecone"
+	"google.golang.org/protobuf/proto"
 )
 
 type recordBatch interface {
 	getNamespace() string
 
-	// isOperationCompatible examines the given record and returns whether the
-	// record can be added to the batch or not.
-	isOperationCompatible(opencdc.Record) bool
+	// isOperationCompatible returns whether a record written with the given
+	// operation can be added to the batch or not.
+	isOperationCompatible(batchOperation) bool
 
-	addRecord(opencdc.Record) error
+	addRecord(context.Context, opencdc.Record) error
 	writeBatch(context.Context, *pinecone.IndexConnection) (int, error)
 }
 
+// requestLimits are the limits of a single upsert or delete request. Batches
+// exceeding them are split in multiple requests.
+type requestLimits struct {
+	maxVectors int
+	maxBytes   int
+}
+
+// writerOptions configure how records are parsed into batches, and how
+// batches are sent to Pinecone.
+type writerOptions struct {
+	parser *vectorParser
+	limits requestLimits
+	retry  RetryConfig
+	// limiter is shared by all batches, nil if rate limiting is disabled.
+	limiter *writeLimiter
+	// partialUpdates makes the update records that don't change the vector
+	// values only update the vector metadata.
+	partialUpdates bool
+	// updateValues makes all the update records update the vector metadata,
+	// and the vector values if they have any.
+	updateValues bool
+	// deleteFilter is nil if delete records don't delete vectors by filter.
+	deleteFilter *deleteFilterTemplate
+	// deleteByPrefix makes delete records delete all the vectors whose ID
+	// starts with their vector ID followed by deletePrefixSeparator.
+	deleteByPrefix        bool
+	deletePrefixSeparator string
+	// embedder is nil if vectors without dense values aren't embedded.
+	embedder Embedder
+	// records is nil unless vectors are upserted as text records.
+	records *recordsUpserter
+}
+
+// batchOperation is how a record is written, which decides the batch it's
+// added to.
+type batchOperation int
+
+const (
+	operationUpsert batchOperation = iota
+	operationUpdate
+	operationDelete
+)
+
+// batchOperation returns how the record is written. It's only called once
+// per record, as telling partial updates apart parses their payloads.
+func (o writerOptions) batchOperation(rec opencdc.Record) batchOperation {
+	switch {
+	case rec.Operation == opencdc.OperationDelete:
+		return operationDelete
+	case rec.Operation != opencdc.OperationUpdate:
+		return operationUpsert
+	case o.updateValues && o.parser.canUpdate():
+		return operationUpdate
+	case o.partialUpdates && o.parser.valuesUnchanged(rec):
+		return operationUpdate
+	default:
+		return operationUpsert
+	}
+}
+
+// newBatch creates an empty batch for records written with the operation.
+func (o writerOptions) newBatch(op batchOperation, namespace string) recordBatch {
+	switch {
+	case op == operationDelete && o.deleteFilter != nil:
+		return &filterDeleteBatch{namespace: namespace, opts: o}
+	case op == operationDelete && o.deleteByPrefix:
+		return &prefixDeleteBatch{namespace: namespace, opts: o}
+	case op == operationDelete:
+		return &deleteBatch{namespace: namespace, opts: o}
+	case op == operationUpdate:
+		return &updateBatch{namespace: namespace, opts: o}
+	default:
+		return &upsertBatch{namespace: namespace, opts: o}
+	}
+}
+
+// vectorOverhead is a rough upper bound of the bytes taken by the field tags
+// and length prefixes of a vector in an upsert request.
+const vectorOverhead = 32
+
+// upsertSize estimates the serialized size of the vector in an upsert
+// request, rounding up.
+func upsertSize(vec *pinecone.Vector) int {
+	size := vectorOverhead + len(vec.Id) + 4*len(vec.Values)
+	if vec.SparseValues != nil {
+		// indices are varints of up to 5 bytes, values are 4 byte floats
+		size += 5*len(vec.SparseValues.Indices) + 4*len(vec.SparseValues.Values)
+	}
+	if vec.Metadata != nil {
+		size += proto.Size(vec.Metadata)
+	}
+	return size
+}
+
+// deleteSize estimates the serialized size of the ID in a delete request,
+// rounding up.
+func deleteSize(id string) int {
+	return len(id) + 8
+}
+
+// splitRequests splits the items in chunks that fit within the limits. An item
+// larger than maxBytes is sent on its own, for Pinecone to reject it.
+func splitRequests[T any](items []T, limits requestLimits, size func(T) int) [][]T {
+	var chunks [][]T
+	var start, bytes int
+	for i, item := range items {
+		itemSize := size(item)
+		if i > start && (i-start == limits.maxVectors || bytes+itemSize > limits.maxBytes) {
+			chunks = append(chunks, items[start:i])
+			start, bytes = i, 0
+		}
+		bytes += itemSize
+	}
+	if start < len(items) {
+		chunks = append(chunks, items[start:])
+	}
+
+	return chunks
+}
+
 type upsertBatch struct {
 	namespace string
+	opts      writerOptions
 	vectors   []*pinecone.Vector
+	// recordEnds holds the number of vectors up to the end of each record, as
+	// records fanned out into chunks have several vectors.
+	recordEnds []int
+	// staleChunks are deleted after the upserts. It closes the batch, as it
+	// belongs to its last record.
+	staleChunks *staleChunks
+	// embeddings are the vectors whose dense values are embedded before the
+	// upserts, all at once, or the text of all the vectors if they're
+	// upserted as text records.
+	embeddings []pendingEmbedding
+}
+
+// pendingEmbedding is a vector of the batch waiting for its dense values to be
+// embedded out of the text.
+type pendingEmbedding struct {
+	vector int
+	text   string
 }
 
 func (b *upsertBatch) getNamespace() string {
 	return b.namespace
 }
 
-func (b *upsertBatch) isOperationCompatible(rec opencdc.Record) bool {
-	switch rec.Operation {
-	case opencdc.OperationCreate, opencdc.OperationUpdate, opencdc.OperationSnapshot:
-		return true
-	case opencdc.OperationDelete:
-		return false
-	}
-	return false
+func (b *upsertBatch) isOperationCompatible(op batchOperation) bool {
+	return b.staleChunks == nil && op == operationUpsert
 }
 
-func (b *upsertBatch) addRecord(rec opencdc.Record) error {
-	vec, err := parsePineconeVector(rec)
+func (b *upsertBatch) addRecord(ctx context.Context, rec opencdc.Record) error {
+	if b.opts.parser.chunks != nil {
+		vectors, texts, stale, err := b.opts.parser.parseChunks(ctx, rec)
+		if err != nil {
+			return err
+		}
+
+		for i, vec := range vectors {
+			b.addVector(vec, texts[i])
+		}
+		b.recordEnds = append(b.recordEnds, len(b.vectors))
+		b.staleChunks = stale
+		return nil
+	}
+
+	vec, text, err := b.opts.parser.parseUpsert(ctx, rec)
 	if err != nil {
 		return err
 	}
 
-	b.vectors = append(b.vectors, vec)
+	b.addVector(vec, text)
+	b.recordEnds = append(b.recordEnds, len(b.vectors))
 	return nil
 }
 
+func (b *upsertBatch) addVector(vec *pinecone.Vector, text string) {
+	if text != "" {
+		b.embeddings = append(b.embeddings, pendingEmbedding{vector: len(b.vectors), text: text})
+	}
+	b.vectors = append(b.vectors, vec)
+}
+
+// embed embeds the texts of the pending vectors into their dense values, then
+// validates them. It returns the number of vectors before the record of the
+// first invalid one, which can still be upserted, with the validation error.
+func (b *upsertBatch) embed(ctx context.Context) (int, error) {
+	if len(b.embeddings) == 0 {
+		return len(b.vectors), nil
+	}
+
+	texts := make([]string, len(b.embeddings))
+	for i, pending := range b.embeddings {
+		texts[i] = pending.text
+	}
+	embeddings, err := b.opts.embedder.Embed(ctx, texts)
+	if err != nil {
+		return 0, fmt.Errorf("failed to embed %d texts: %w", len(texts), err)
+	}
+	if len(embeddings) != len(texts) {
+		return 0, fmt.Errorf("embedder returned %d embeddings for %d texts", len(embeddings), len(texts))
+	}
+
+	for i, pending := range b.embeddings {
+		vec := b.vectors[pending.vector]
+		vec.Values = embeddings[i]
+		if err := b.opts.parser.validate(vec); err != nil {
+			return b.recordStart(pending.vector), fmt.Errorf("invalid embedding: %w", err)
+		}
+	}
+	b.embeddings = nil
+	return len(b.vectors), nil
+}
+
+// recordStart returns the index of the first vector of the record the given
+// vector belongs to.
+func (b *upsertBatch) recordStart(vector int) int {
+	var start int
+	for _, end := range b.recordEnds {
+		if end > vector {
+			break
+		}
+		start = end
+	}
+	return start
+}
+
 func (b *upsertBatch) writeBatch(ctx context.Context, index *pinecone.IndexConnection) (int, error) {
-	written, err := index.UpsertVectors(ctx, b.vectors)
+	var upserted int
+	var err error
+	if b.opts.records != nil {
+		upserted, err = b.upsertRecords(ctx)
+	} else {
+		upserted, err = b.upsertVectors(ctx, index)
+	}
+	written := b.writtenRecords(upserted)
+	if err != nil {
+		return written, err
+	}
+	if b.staleChunks == nil {
+		return written, nil
+	}
+
+	// the last record, the one with stale chunks, isn't written until they
+	// are deleted
+	deleted, err := deletePrefix(ctx, index, b.opts, b.namespace, b.staleChunks.prefix, b.staleChunks.isStale)
+	if err != nil {
+		return min(written, len(b.recordEnds)-1), fmt.Errorf("failed to delete stale chunks with prefix %q: %w", b.staleChunks.prefix, err)
+	}
+
+	sdk.Logger(ctx).Debug().
+		Str("prefix", b.staleChunks.prefix).
+		Int("deleted", deleted).
+		Msg("deleted stale chunks")
+	return written, nil
+}
+
+// upsertVectors embeds the pending vectors and upserts all of them, returning
+// the number of upserted vectors. If an embedding is invalid, the vectors of
+// the records before it are upserted before failing.
+func (b *upsertBatch) upsertVectors(ctx context.Context, index *pinecone.IndexConnection) (int, error) {
+	valid, embedErr := b.embed(ctx)
+
+	var upserted int
+	for _, vectors := range splitRequests(b.vectors[:valid], b.opts.limits, upsertSize) {
+		ids := make([]string, len(vectors))
+		for i, vec := range vectors {
+			ids[i] = vec.Id
+		}
+
+		// only the failed request is retried, so the order of the records
+		// is kept
+		var count uint32
+		err := withRetries(ctx, b.opts.retry, ids, func(ctx context.Context) error {
+			if err := b.opts.limiter.wait(ctx, b.namespace, len(vectors)); err != nil {
+				return err
+			}
+
+			var err error
+			count, err = index.UpsertVectors(ctx, vectors)
+			return err //nolint:wrapcheck // wrapped by withRetries
+		})
+		if err != nil {
+			return upserted, fmt.Errorf("failed to upsert vectors: %w", err)
+		}
+		upserted += int(count)
+	}
+	return upserted, embedErr
+}
+
+// upsertRecords upserts the vectors as text records, embedded by the index,
+// returning the number of upserted records.
+func (b *upsertBatch) upsertRecords(ctx context.Context) (int, error) {
+	texts := make([]string, len(b.vectors))
+	for _, pending := range b.embeddings {
+		texts[pending.vector] = pending.text
+	}
+
+	records := make([]textRecord, len(b.vectors))
+	for i, vec := range b.vectors {
+		var err error
+		if records[i], err = b.opts.records.record(vec, texts[i]); err != nil {
+			return 0, err
+		}
+	}
+
+	var upserted int
+	for _, batch := range splitRequests(records, b.opts.records.limits, textRecord.size) {
+		ids := make([]string, len(batch))
+		for i, rec := range batch {
+			ids[i] = rec.id
+		}
+
+		err := withRetries(ctx, b.opts.retry, ids, func(ctx context.Context) error {
+			if err := b.opts.limiter.wait(ctx, b.namespace, len(batch)); err != nil {
+				return err
+			}
+			return b.opts.records.upsert(ctx, b.namespace, batch)
+		})
+		if err != nil {
+			return upserted, fmt.Errorf("failed to upsert records: %w", err)
+		}
+		upserted += len(batch)
+	}
+	return upserted, nil
+}
+
+// writtenRecords returns the number of records whose vectors are all within
+// the upserted ones.
+func (b *upsertBatch) writtenRecords(upserted int) int {
+	var written int
+	for _, end := range b.recordEnds {
+		if end > upserted {
+			break
+		}
+		written++
+	}
+	return written
+}
+
+// updateBatch updates the metadata, and optionally the values, of existing
+// vectors, one request per vector, as Pinecone has no bulk update.
+type updateBatch struct {
+	namespace string
+	opts      writerOptions
+	vectors   []*pinecone.Vector
+}
+
+func (b *updateBatch) getNamespace() string {
+	return b.namespace
+}
+
+func (b *updateBatch) isOperationCompatible(op batchOperation) bool {
+	return op == operationUpdate
+}
+
+func (b *updateBatch) addRecord(ctx context.Context, rec opencdc.Record) error {
+	vec, err := b.opts.parser.parseUpdate(ctx, rec, b.opts.updateValues)
 	if err != nil {
-		return 0, fmt.Errorf("failed to upsert vectors: %w", err)
+		return err
 	}
-	return int(written), err
+
+	b.vectors = append(b.vectors, vec)
+	return nil
+}
+
+func (b *updateBatch) writeBatch(ctx context.Context, index *pinecone.IndexConnection) (int, error) {
+	var written int
+	for _, vec := range b.vectors {
+		err := withRetries(ctx, b.opts.retry, []string{vec.Id}, func(ctx context.Context) error {
+			if err := b.opts.limiter.wait(ctx, b.namespace, 1); err != nil {
+				return err
+			}
+
+			//nolint:wrapcheck // wrapped by withRetries
+			return index.UpdateVector(ctx, &pinecone.UpdateVectorRequest{
+				Id:           vec.Id,
+				Values:       vec.Values,
+				SparseValues: vec.SparseValues,
+				Metadata:     vec.Metadata,
+			})
+		})
+		if err != nil {
+			return written, fmt.Errorf("failed to update vector: %w", err)
+		}
+		written++
+	}
+	return written, nil
 }
 
 type deleteBatch struct {
 	namespace string
+	opts      writerOptions
 	ids       []string
 }
 
@@ -84,23 +439,35 @@ func (b *deleteBatch) getNamespace() string {
 	return b.namespace
 }
 
-func (b *deleteBatch) isOperationCompatible(rec opencdc.Record) bool {
-	return rec.Operation == opencdc.OperationDelete
+func (b *deleteBatch) isOperationCompatible(op batchOperation) bool {
+	return op == operationDelete
 }
 
-func (b *deleteBatch) addRecord(rec opencdc.Record) error {
-	id := vectorID(rec.Key)
+func (b *deleteBatch) addRecord(_ context.Context, rec opencdc.Record) error {
+	id, _, err := b.opts.parser.vectorID(rec)
+	if err != nil {
+		return err
+	}
 	b.ids = append(b.ids, id)
 	return nil
 }
 
 func (b *deleteBatch) writeBatch(ctx context.Context, index *pinecone.IndexConnection) (int, error) {
-	err := index.DeleteVectorsById(ctx, b.ids)
-	if err != nil {
-		return 0, fmt.Errorf("failed to delete vectors: %w", err)
+	var written int
+	for _, ids := range splitRequests(b.ids, b.opts.limits, deleteSize) {
+		err := withRetries(ctx, b.opts.retry, ids, func(ctx context.Context) error {
+			if err := b.opts.limiter.wait(ctx, b.namespace, len(ids)); err != nil {
+				return err
+			}
+			return index.DeleteVectorsById(ctx, ids) //nolint:wrapcheck // wrapped by withRetries
+		})
+		if err != nil {
+			return written, fmt.Errorf("failed to delete vectors: %w", err)
+		}
+		written += len(ids)
 	}
 
-	return len(b.ids), nil
+	return written, nil
 }
 
 type collectionWriter interface {
@@ -110,15 +477,21 @@ type collectionWriter interface {
 
 type multicollectionWriter struct {
 	apiKey, host string
+	tls          TLSConfig
+	opts         writerOptions
 
 	indexes           cmap.ConcurrentMap[string, *pinecone.IndexConnection]
 	namespaceTemplate *template.Template
 }
 
-func newMulticollectionWriter(apiKey, host string, template *template.Template) *multicollectionWriter {
+func newMulticollectionWriter(
+	apiKey, host string, tls TLSConfig, opts writerOptions, template *template.Template,
+) *multicollectionWriter {
 	return &multicollectionWriter{
 		apiKey:            apiKey,
 		host:              host,
+		tls:               tls,
+		opts:              opts,
 		indexes:           cmap.New[*pinecone.IndexConnection](),
 		namespaceTemplate: template,
 	}
@@ -146,6 +519,7 @@ func (w *multicollectionWriter) addIndexIfMissing(ctx context.Context, namespace
 	index, err := newIndex(ctx, newIndexParams{
 		apiKey:    w.apiKey,
 		host:      w.host,
+		tls:       w.tls,
 		namespace: namespace,
 	})
 	if err != nil {
@@ -161,16 +535,9 @@ func (w *multicollectionWriter) addIndexIfMissing(ctx context.Context, namespace
 func (w *multicollectionWriter) buildBatches(ctx context.Context, records []opencdc.Record) ([]recordBatch, error) {
 	var batches []recordBatch
 
-	addNewBatch := func(rec opencdc.Record, namespace string) error {
-		var batch recordBatch
-
-		if rec.Operation == opencdc.OperationDelete {
-			batch = &deleteBatch{namespace: namespace}
-		} else {
-			batch = &upsertBatch{namespace: namespace}
-		}
-
-		if err := batch.addRecord(rec); err != nil {
+	addNewBatch := func(rec opencdc.Record, op batchOperation, namespace string) error {
+		batch := w.opts.newBatch(op, namespace)
+		if err := batch.addRecord(ctx, rec); err != nil {
 			return fmt.Errorf("failed to add record: %w", err)
 		}
 
@@ -178,39 +545,40 @@ func (w *multicollectionWriter) buildBatches(ctx context.Context, records []open
 		return nil
 	}
 
-	addToPreviousBatch := func(rec opencdc.Record, namespace string) error {
+	addToPreviousBatch := func(rec opencdc.Record, op batchOperation, namespace string) error {
 		prevBatch := batches[len(batches)-1]
 
 		if prevBatch.getNamespace() != namespace {
-			return addNewBatch(rec, namespace)
+			return addNewBatch(rec, op, namespace)
 		}
 
-		if prevBatch.isOperationCompatible(rec) {
-			return prevBatch.addRecord(rec)
+		if prevBatch.isOperationCompatible(op) {
+			return prevBatch.addRecord(ctx, rec)
 		}
-		return addNewBatch(rec, namespace)
+		return addNewBatch(rec, op, namespace)
 	}
 
 	for _, rec := range records {
 		namespace, err := w.parseNamespace(rec)
 		if err != nil {
-			return nil, fmt.Errorf("failed to parse namespace: %w", err)
+			return batches, fmt.Errorf("failed to parse namespace: %w", err)
 		}
 
 		// Note: we could parallelize the index creation, but for the few
 		// different namespaces that the connector is going to receive it should
 		// not be that problematic. See in the future if it's worth it.
 		if err := w.addIndexIfMissing(ctx, namespace); err != nil {
-			return nil, fmt.Errorf("failed to add missing index: %w", err)
+			return batches, fmt.Errorf("failed to add missing index: %w", err)
 		}
 
+		op := w.opts.batchOperation(rec)
 		if len(batches) == 0 {
-			err = addNewBatch(rec, namespace)
+			err = addNewBatch(rec, op, namespace)
 		} else {
-			err = addToPreviousBatch(rec, namespace)
+			err = addToPreviousBatch(rec, op, namespace)
 		}
 		if err != nil {
-			return nil, err
+			return batches, err
 		}
 	}
 
@@ -218,11 +586,17 @@ func (w *multicollectionWriter) buildBatches(ctx context.Context, records []open
 }
 
 func (w *multicollectionWriter) writeRecords(ctx context.Context, records []opencdc.Record) (int, error) {
+	// the batches built before a record that can't be written are written
+	// anyway, so that Conduit only nacks that record onwards
 	batches, err := w.buildBatches(ctx, records)
-	if err != nil {
-		return 0, err
+	written, writeErr := w.writeBatches(ctx, batches)
+	if writeErr != nil {
+		return written, writeErr
 	}
+	return written, err
+}
 
+func (w *multicollectionWriter) writeBatches(ctx context.Context, batches []recordBatch) (int, error) {
 	var written int
 	for _, batch := range batches {
 		namespace := batch.getNamespace()
@@ -257,21 +631,15 @@ func (w *multicollectionWriter) close() error {
 
 type singleCollectionWriter struct {
 	index *pinecone.IndexConnection
+	opts  writerOptions
 }
 
-func (w *singleCollectionWriter) buildBatches(records []opencdc.Record) ([]recordBatch, error) {
+func (w *singleCollectionWriter) buildBatches(ctx context.Context, records []opencdc.Record) ([]recordBatch, error) {
 	var batches []recordBatch
 
-	addNewBatch := func(rec opencdc.Record) error {
-		var batch recordBatch
-
-		if rec.Operation == opencdc.OperationDelete {
-			batch = &deleteBatch{}
-		} else {
-			batch = &upsertBatch{}
-		}
-
-		if err := batch.addRecord(rec); err != nil {
+	addNewBatch := func(rec opencdc.Record, op batchOperation) error {
+		batch := w.opts.newBatch(op, "")
+		if err := batch.addRecord(ctx, rec); err != nil {
 			return fmt.Errorf("failed to add record: %w", err)
 		}
 
@@ -279,21 +647,22 @@ func (w *singleCollectionWriter) buildBatches(records []opencdc.Record) ([]recor
 		return nil
 	}
 
-	addToPreviousBatch := func(rec opencdc.Record) error {
+	addToPreviousBatch := func(rec opencdc.Record, op batchOperation) error {
 		prevBatch := batches[len(batches)-1]
 
-		if prevBatch.isOperationCompatible(rec) {
-			return prevBatch.addRecord(rec)
+		if prevBatch.isOperationCompatible(op) {
+			return prevBatch.addRecord(ctx, rec)
 		}
-		return addNewBatch(rec)
+		return addNewBatch(rec, op)
 	}
 
 	for _, rec := range records {
 		var err error
+		op := w.opts.batchOperation(rec)
 		if len(batches) == 0 {
-			err = addNewBatch(rec)
+			err = addNewBatch(rec, op)
 		} else {
-			err = addToPreviousBatch(rec)
+			err = addToPreviousBatch(rec, op)
 		}
 		if err != nil {
 			return batches, err
@@ -304,11 +673,17 @@ func (w *singleCollectionWriter) buildBatches(records []opencdc.Record) ([]recor
 }
 
 func (w *singleCollectionWriter) writeRecords(ctx context.Context, records []opencdc.Record) (int, error) {
-	batches, err := w.buildBatches(records)
-	if err != nil {
-		return 0, err
+	// the batches built before a record that can't be written are written
+	// anyway, so that Conduit only nacks that record onwards
+	batches, err := w.buildBatches(ctx, records)
+	written, writeErr := w.writeBatches(ctx, batches)
+	if writeErr != nil {
+		return written, writeErr
 	}
+	return written, err
+}
 
+func (w *singleCollectionWriter) writeBatches(ctx context.Context, batches []recordBatch) (int, error) {
 	var written int
 	for _, batch := range batches {
 		batchWrittenRecs, err := batch.writeBatch(ctx, w.index)
@@ -18,6 +18,7 @@ import (
 	"context"
 	"encoding/json"
 	"math/rand"
+	"strings"
 	"testing"
 	"text/template"
 
@@ -34,7 +35,7 @@ func assertUpsertBatch(is *is.I, batch recordBatch, records []opencdc.Record) {
 	for i, vec := range upsertBatch.vectors {
 		rec := records[i]
 
-		parsed, err := parsePineconeVector(rec)
+		parsed, err := defaultVectorParser.parse(context.Background(), rec)
 		is.NoErr(err)
 
 		is.Equal(vec, parsed)
@@ -54,12 +55,12 @@ func assertDeleteBatch(is *is.I, batch recordBatch, records []opencdc.Record) {
 }
 
 func TestSingleCollectionWriter(t *testing.T) {
-	colWriter := singleCollectionWriter{}
+	colWriter := singleCollectionWriter{opts: writerOptions{parser: defaultVectorParser}}
 
 	t.Run("empty", func(t *testing.T) {
 		is := is.New(t)
 		var records []opencdc.Record
-		batches, err := colWriter.buildBatches(records)
+		batches, err := colWriter.buildBatches(context.Background(), records)
 		is.NoErr(err)
 
 		is.Equal(len(batches), 0)
@@ -68,7 +69,7 @@ func TestSingleCollectionWriter(t *testing.T) {
 	t.Run("only delete", func(t *testing.T) {
 		is := is.New(t)
 		records := testRecords(opencdc.OperationDelete)
-		batches, err := colWriter.buildBatches(records)
+		batches, err := colWriter.buildBatches(context.Background(), records)
 		is.NoErr(err)
 
 		is.Equal(len(batches), 1)
@@ -78,7 +79,7 @@ func TestSingleCollectionWriter(t *testing.T) {
 	t.Run("only non delete", func(t *testing.T) {
 		is := is.New(t)
 		records := testRecords(opencdc.OperationCreate)
-		batches, err := colWriter.buildBatches(records)
+		batches, err := colWriter.buildBatches(context.Background(), records)
 		is.NoErr(err)
 
 		is.Equal(len(batches), 1)
@@ -103,7 +104,7 @@ func TestSingleCollectionWriter(t *testing.T) {
 		batch4 := testRecords(opencdc.OperationSnapshot)
 		records = append(records, batch4...)
 
-		batches, err := colWriter.buildBatches(records)
+		batches, err := colWriter.buildBatches(context.Background(), records)
 		is.NoErr(err)
 
 		is.Equal(len(batches), 5)
@@ -116,12 +117,122 @@ func TestSingleCollectionWriter(t *testing.T) {
 	})
 }
 
+func TestSingleCollectionWriter_PartialUpdates(t *testing.T) {
+	is := is.New(t)
+	colWriter := singleCollectionWriter{opts: writerOptions{
+		parser:         defaultVectorParser,
+		partialUpdates: true,
+	}}
+
+	vectorPayload := opencdc.RawData(`{"values": [1, 2]}`)
+	record := func(op opencdc.Operation, before, after opencdc.Data) opencdc.Record {
+		return opencdc.Record{
+			Operation: op,
+			Key:       opencdc.RawData(randString()),
+			Metadata:  opencdc.Metadata{"title": "hello"},
+			Payload:   opencdc.Change{Before: before, After: after},
+		}
+	}
+
+	records := []opencdc.Record{
+		record(opencdc.OperationCreate, nil, vectorPayload),
+		// no vector values
+		record(opencdc.OperationUpdate, nil, opencdc.RawData(`{}`)),
+		// same vector values
+		record(opencdc.OperationUpdate, vectorPayload, vectorPayload),
+		// changed vector values
+		record(opencdc.OperationUpdate, vectorPayload, opencdc.RawData(`{"values": [3, 4]}`)),
+		record(opencdc.OperationDelete, nil, nil),
+	}
+
+	batches, err := colWriter.buildBatches(context.Background(), records)
+	is.NoErr(err)
+	is.Equal(len(batches), 4)
+
+	_, ok := batches[0].(*upsertBatch)
+	is.True(ok)
+
+	updates, ok := batches[1].(*updateBatch)
+	is.True(ok)
+	is.Equal(len(updates.vectors), 2)
+	for _, vec := range updates.vectors {
+		is.Equal(vec.Values, nil)
+		is.Equal(vec.SparseValues, nil)
+		is.Equal(vec.Metadata.AsMap(), map[string]any{"title": "hello"})
+	}
+
+	upserts, ok := batches[2].(*upsertBatch)
+	is.True(ok)
+	is.Equal(upserts.vectors[0].Values, []float32{3, 4})
+
+	_, ok = batches[3].(*deleteBatch)
+	is.True(ok)
+}
+
+func TestSingleCollectionWriter_UpdateValues(t *testing.T) {
+	is := is.New(t)
+	colWriter := singleCollectionWriter{opts: writerOptions{
+		parser:       defaultVectorParser,
+		updateValues: true,
+	}}
+
+	record := func(op opencdc.Operation, after opencdc.Data) opencdc.Record {
+		return opencdc.Record{
+			Operation: op,
+			Key:       opencdc.RawData(randString()),
+			Metadata:  opencdc.Metadata{"title": "hello"},
+			Payload:   opencdc.Change{After: after},
+		}
+	}
+
+	records := []opencdc.Record{
+		record(opencdc.OperationCreate, opencdc.RawData(`{"values": [1, 2]}`)),
+		// no vector values
+		record(opencdc.OperationUpdate, opencdc.RawData(`{}`)),
+		// changed vector values
+		record(opencdc.OperationUpdate, opencdc.RawData(`{"values": [3, 4]}`)),
+		record(opencdc.OperationDelete, nil),
+	}
+
+	batches, err := colWriter.buildBatches(context.Background(), records)
+	is.NoErr(err)
+	is.Equal(len(batches), 3)
+
+	_, ok := batches[0].(*upsertBatch)
+	is.True(ok)
+
+	updates, ok := batches[1].(*updateBatch)
+	is.True(ok)
+	is.Equal(len(updates.vectors), 2)
+	is.Equal(updates.vectors[0].Values, nil)
+	is.Equal(updates.vectors[1].Values, []float32{3, 4})
+	for _, vec := range updates.vectors {
+		is.Equal(vec.Metadata.AsMap(), map[string]any{"title": "hello"})
+	}
+
+	_, ok = batches[2].(*deleteBatch)
+	is.True(ok)
+
+	t.Run("invalid values", func(t *testing.T) {
+		is := is.New(t)
+
+		_, err := colWriter.buildBatches(context.Background(), []opencdc.Record{
+			record(opencdc.OperationUpdate, opencdc.RawData(`{"sparse_values": {"indices": [2, 1], "values": [1, 2]}}`)),
+		})
+		is.True(err != nil)
+		is.True(strings.Contains(err.Error(), "ascending order"))
+	})
+}
+
 func setupMulticollection(t *testing.T) (context.Context, *is.I, *multicollectionWriter) {
 	cfg := destConfigFromEnv(t)
 
-	colWriter := newMulticollectionWriter(cfg.APIKey, cfg.Host, nil)
-	ctx := context.Background()
 	is := is.New(t)
+	opts, err := cfg.writerOptions(nil)
+	is.NoErr(err)
+
+	colWriter := newMulticollectionWriter(cfg.APIKey, cfg.Host, cfg.TLS, opts, nil)
+	ctx := context.Background()
 
 	return ctx, is, colWriter
 }
@@ -278,7 +389,7 @@ func testRecordsWithNamespace(op opencdc.Operation, namespace string) []opencdc.
 
 		vecValues := pineconeVectorValues{
 			Values: []float32{1, 2},
-			SparseValues: sparseValues{
+			SparseValues: &sparseValues{
 				Indices: []uint32{3, 5},
 				Values:  []float32{0.5, 0.3},
 			},
@@ -334,7 +445,7 @@ func assertUpsertRecordsWrittenInNamespace(
 				continue
 			}
 
-			parsedVec, err := parsePineconeVector(rec)
+			parsedVec, err := defaultVectorParser.parse(context.Background(), rec)
 			is.NoErr(err)
 
 			is.Equal(vec, parsedVec)
@@ -344,3 +455,50 @@ func assertUpsertRecordsWrittenInNamespace(
 
 	return index
 }
+
+func TestSplitRequests(t *testing.T) {
+	size := func(s string) int { return len(s) }
+
+	testCases := []struct {
+		name   string
+		items  []string
+		limits requestLimits
+		want   [][]string
+	}{
+		{
+			name:   "empty",
+			limits: requestLimits{maxVectors: 2, maxBytes: 10},
+		},
+		{
+			name:   "within limits",
+			items:  []string{"a", "b"},
+			limits: requestLimits{maxVectors: 2, maxBytes: 10},
+			want:   [][]string{{"a", "b"}},
+		},
+		{
+			name:   "by count",
+			items:  []string{"a", "b", "c", "d", "e"},
+			limits: requestLimits{maxVectors: 2, maxBytes: 10},
+			want:   [][]string{{"a", "b"}, {"c", "d"}, {"e"}},
+		},
+		{
+			name:   "by size",
+			items:  []string{"aaa", "bbb", "ccc", "d"},
+			limits: requestLimits{maxVectors: 10, maxBytes: 7},
+			want:   [][]string{{"aaa", "bbb"}, {"ccc", "d"}},
+		},
+		{
+			name:   "item larger than max size",
+			items:  []string{"a", "bbbbbbbbbb", "c"},
+			limits: requestLimits{maxVectors: 10, maxBytes: 5},
+			want:   [][]string{{"a"}, {"bbbbbbbbbb"}, {"c"}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			is := is.New(t)
+			is.Equal(splitRequests(tc.items, tc.limits, size), tc.want)
+		})
+	}
+}
@@ -21,6 +21,6 @@ import (
 // Connector combines all constructors for each plugin in one struct.
 var Connector = sdk.Connector{
 	NewSpecification: Specification,
-	NewSource:        nil,
+	NewSource:        NewSource,
 	NewDestination:   NewDestination,
 }
@@ -0,0 +1,140 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pinecone
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"strings"
+	"text/template"
+
+	"github.com/conduitio/conduit-commons/opencdc"
+	"github.com/pinecone-io/go-pinecone/pinecone"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+const (
+	deleteModeID     = "id"
+	deleteModeFilter = "filter"
+	deleteModePrefix = "prefix"
+)
+
+// deleteFilterFuncs are the helper functions available in the delete filter
+// template, on top of the ones of the id template.
+var deleteFilterFuncs = func() template.FuncMap {
+	funcs := maps.Clone(vectorIDFuncs)
+	// json encodes the value, so that strings are quoted and escaped, e.g.
+	// {"doc_id": {{ json .Key.doc_id }}}.
+	funcs["json"] = func(value any) (string, error) {
+		if data, ok := value.(opencdc.Data); ok {
+			value = templateString(data)
+		}
+		bs, err := json.Marshal(value)
+		return string(bs), err
+	}
+	return funcs
+}()
+
+// deleteFilterTemplate renders the metadata filters of the vectors deleted by
+// the delete records.
+type deleteFilterTemplate struct {
+	template *template.Template
+}
+
+// newDeleteFilterTemplate parses the delete filter template. It returns nil if
+// the delete mode doesn't delete by filter.
+func newDeleteFilterTemplate(mode, tmpl string) (*deleteFilterTemplate, error) {
+	if mode != deleteModeFilter {
+		return nil, nil //nolint:nilnil // deletes are by ID
+	}
+	if tmpl == "" {
+		return nil, errors.New("deleteFilter is required by the filter delete mode")
+	}
+
+	t, err := template.New("deleteFilter").Funcs(deleteFilterFuncs).Option("missingkey=error").Parse(tmpl)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse delete filter template %s: %w", tmpl, err)
+	}
+	return &deleteFilterTemplate{template: t}, nil
+}
+
+// filter renders the metadata filter of the record.
+func (t *deleteFilterTemplate) filter(rec opencdc.Record) (*pinecone.MetadataFilter, error) {
+	var sb strings.Builder
+	if err := t.template.Execute(&sb, rec); err != nil {
+		return nil, fmt.Errorf("failed to execute delete filter template: %w", err)
+	}
+
+	var filter map[string]any
+	if err := json.Unmarshal([]byte(sb.String()), &filter); err != nil {
+		return nil, fmt.Errorf("delete filter %s is not a JSON object: %w", sb.String(), err)
+	}
+	// an empty filter would match every vector of the namespace
+	if len(filter) == 0 {
+		return nil, errors.New("delete filter is empty")
+	}
+
+	metadataFilter, err := structpb.NewStruct(filter)
+	if err != nil {
+		return nil, fmt.Errorf("error protobuf struct: %w", err)
+	}
+	return metadataFilter, nil
+}
+
+// filterDeleteBatch deletes the vectors matching the metadata filters of the
+// delete records, one request per record.
+type filterDeleteBatch struct {
+	namespace string
+	opts      writerOptions
+	filters   []*pinecone.MetadataFilter
+}
+
+func (b *filterDeleteBatch) getNamespace() string {
+	return b.namespace
+}
+
+func (b *filterDeleteBatch) isOperationCompatible(op batchOperation) bool {
+	return op == operationDelete
+}
+
+func (b *filterDeleteBatch) addRecord(_ context.Context, rec opencdc.Record) error {
+	filter, err := b.opts.deleteFilter.filter(rec)
+	if err != nil {
+		return err
+	}
+
+	b.filters = append(b.filters, filter)
+	return nil
+}
+
+func (b *filterDeleteBatch) writeBatch(ctx context.Context, index *pinecone.IndexConnection) (int, error) {
+	var written int
+	for _, filter := range b.filters {
+		err := withRetries(ctx, b.opts.retry, nil, func(ctx context.Context) error {
+			if err := b.opts.limiter.wait(ctx, b.namespace, 1); err != nil {
+				return err
+			}
+			return index.DeleteVectorsByFilter(ctx, filter) //nolint:wrapcheck // wrapped by withRetries
+		})
+		if err != nil {
+			return written, fmt.Errorf("failed to delete vectors by filter %s: %w", jsonString(filter.AsMap()), err)
+		}
+		written++
+	}
+	return written, nil
+}
@@ -0,0 +1,90 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pinecone
+
+import (
+	"testing"
+
+	"github.com/conduitio/conduit-commons/opencdc"
+	"github.com/matryer/is"
+)
+
+func TestDeleteFilterTemplate(t *testing.T) {
+	testCases := []struct {
+		name     string
+		template string
+		key      opencdc.Data
+		want     map[string]any
+		wantErr  bool
+	}{
+		{
+			name:     "structured key field",
+			template: `{"doc_id": {{ json .Key.doc }}}`,
+			key:      opencdc.StructuredData{"doc": `say "hi"`},
+			want:     map[string]any{"doc_id": `say "hi"`},
+		},
+		{
+			name:     "raw key",
+			template: `{"doc_id": {"$eq": {{ json .Key }}}}`,
+			key:      opencdc.RawData("doc1"),
+			want:     map[string]any{"doc_id": map[string]any{"$eq": "doc1"}},
+		},
+		{
+			name:     "metadata",
+			template: `{"source": {{ json (index .Metadata "source") }}, "doc_id": {{ json (string .Key) }}}`,
+			key:      opencdc.RawData("doc1"),
+			want:     map[string]any{"source": "crm", "doc_id": "doc1"},
+		},
+		{name: "not json", template: `doc_id = {{ string .Key }}`, key: opencdc.RawData("doc1"), wantErr: true},
+		{name: "not an object", template: `[{{ json .Key }}]`, key: opencdc.RawData("doc1"), wantErr: true},
+		{name: "empty filter", template: `{}`, key: opencdc.RawData("doc1"), wantErr: true},
+		{name: "missing key field", template: `{"doc_id": {{ json .Key.missing }}}`, key: opencdc.StructuredData{}, wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			is := is.New(t)
+
+			tmpl, err := newDeleteFilterTemplate(deleteModeFilter, tc.template)
+			is.NoErr(err)
+
+			got, err := tmpl.filter(opencdc.Record{
+				Operation: opencdc.OperationDelete,
+				Key:       tc.key,
+				Metadata:  opencdc.Metadata{"source": "crm"},
+			})
+			if tc.wantErr {
+				is.True(err != nil)
+				return
+			}
+			is.NoErr(err)
+			is.Equal(got.AsMap(), tc.want)
+		})
+	}
+
+	t.Run("invalid config", func(t *testing.T) {
+		is := is.New(t)
+
+		_, err := newDeleteFilterTemplate(deleteModeFilter, "")
+		is.True(err != nil)
+
+		_, err = newDeleteFilterTemplate(deleteModeFilter, `{{ .Key`)
+		is.True(err != nil)
+
+		tmpl, err := newDeleteFilterTemplate(deleteModeID, `{"doc_id": 1}`)
+		is.NoErr(err)
+		is.True(tmpl == nil)
+	})
+}
@@ -0,0 +1,126 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pinecone
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/conduitio/conduit-commons/opencdc"
+	sdk "github.com/conduitio/conduit-connector-sdk"
+	"github.com/pinecone-io/go-pinecone/pinecone"
+)
+
+// maxListLimit is the maximum number of vector IDs in a page listed by
+// Pinecone.
+const maxListLimit = 100
+
+// prefixDeleteBatch deletes all the vectors whose ID starts with the prefix
+// of the delete records, like the chunks of a document.
+type prefixDeleteBatch struct {
+	namespace string
+	opts      writerOptions
+	prefixes  []string
+}
+
+func (b *prefixDeleteBatch) getNamespace() string {
+	return b.namespace
+}
+
+func (b *prefixDeleteBatch) isOperationCompatible(op batchOperation) bool {
+	return op == operationDelete
+}
+
+// addRecord adds the prefix of the record, which is its vector ID followed by
+// the separator. The vector ID is replaced like the one of generated chunk
+// IDs, so that the prefix matches them.
+func (b *prefixDeleteBatch) addRecord(_ context.Context, rec opencdc.Record) error {
+	prefix, _, err := b.opts.parser.vectorIDPrefix(rec, b.opts.deletePrefixSeparator)
+	if err != nil {
+		return err
+	}
+
+	b.prefixes = append(b.prefixes, prefix)
+	return nil
+}
+
+func (b *prefixDeleteBatch) writeBatch(ctx context.Context, index *pinecone.IndexConnection) (int, error) {
+	var written int
+	for _, prefix := range b.prefixes {
+		deleted, err := deletePrefix(ctx, index, b.opts, b.namespace, prefix, nil)
+		if err != nil {
+			return written, fmt.Errorf("failed to delete vectors with prefix %q: %w", prefix, err)
+		}
+
+		sdk.Logger(ctx).Debug().
+			Str("prefix", prefix).
+			Int("deleted", deleted).
+			Msg("deleted vectors by prefix")
+		written++
+	}
+	return written, nil
+}
+
+// deletePrefix lists all the vector IDs with the prefix, then deletes the ones
+// matching the filter, or all of them if the filter is nil, in requests within
+// the limits. The IDs are listed before any deletion, so that deletions don't
+// shift the pages. It returns the number of deleted vectors.
+func deletePrefix(
+	ctx context.Context, index *pinecone.IndexConnection,
+	opts writerOptions, namespace, prefix string,
+	filter func(id string) bool,
+) (int, error) {
+	limit := uint32(min(opts.limits.maxVectors, maxListLimit))
+
+	var ids []string
+	var token *string
+	for {
+		var page []string
+		var next *string
+		err := withRetries(ctx, opts.retry, nil, func(ctx context.Context) error {
+			var err error
+			page, next, err = listVectorIDs(ctx, index, prefix, limit, token)
+			return err
+		})
+		if err != nil {
+			return 0, fmt.Errorf("failed to list vectors with prefix %q: %w", prefix, err)
+		}
+
+		for _, id := range page {
+			if filter == nil || filter(id) {
+				ids = append(ids, id)
+			}
+		}
+		if next == nil {
+			break
+		}
+		token = next
+	}
+
+	var deleted int
+	for _, chunk := range splitRequests(ids, opts.limits, deleteSize) {
+		err := withRetries(ctx, opts.retry, chunk, func(ctx context.Context) error {
+			if err := opts.limiter.wait(ctx, namespace, len(chunk)); err != nil {
+				return err
+			}
+			return index.DeleteVectorsById(ctx, chunk) //nolint:wrapcheck // wrapped by withRetries
+		})
+		if err != nil {
+			return deleted, fmt.Errorf("failed to delete vectors: %w", err)
+		}
+		deleted += len(chunk)
+	}
+	return deleted, nil
+}
@@ -18,9 +18,7 @@ package pinecone
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"net/url"
 	"strings"
 	"text/template"
 
@@ -28,7 +26,7 @@ import (
 	"github.com/conduitio/conduit-commons/opencdc"
 	sdk "github.com/conduitio/conduit-connector-sdk"
 	"github.com/pinecone-io/go-pinecone/pinecone"
-	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/grpc"
 )
 
 type Destination struct {
@@ -46,17 +44,245 @@ type DestinationConfig struct {
 	// Host is the whole Pinecone index host URL.
 	Host string `json:"host" validate:"required"`
 
+	// TLS contains the settings used to connect to https hosts. Hosts with
+	// the http scheme, like the Pinecone Local emulator, are connected to over
+	// plaintext.
+	TLS TLSConfig `json:"tls"`
+
+	// IndexName is the name of the index. If set, the index is described by
+	// the control plane when the destination is opened, which tells its
+	// vector type and metric. Otherwise, its host only tells its dimension,
+	// and indexes without one, like sparse indexes, fail to open. The index
+	// isn't described when upserting text records, which don't need it.
+	IndexName string `json:"indexName"`
+
+	// ControlPlaneHost is the URL of the Pinecone control plane, which
+	// describes the index named by indexName.
+	ControlPlaneHost string `json:"controlPlaneHost" default:"https://api.pinecone.io"`
+
 	// Namespace is the Pinecone's index namespace. Defaults to the empty
 	// namespace. It can contain a [Go template](https://pkg.go.dev/text/template)
 	// that will be executed for each record to determine the namespace.
 	Namespace string `json:"namespace"`
+
+	// ID is a [Go template](https://pkg.go.dev/text/template) executed for
+	// each record to build the vector ID, used by both upserts and deletes.
+	// The join, prefix, sha256 and string functions help building composite
+	// IDs out of structured keys. Defaults to the record key.
+	ID string `json:"id"`
+
+	// InvalidID configures how the vector IDs that Pinecone would reject are
+	// handled.
+	InvalidID InvalidIDConfig `json:"invalidID"`
+
+	// MaxRequestVectors is the maximum number of vectors upserted or deleted
+	// in a single request. Larger batches are split in multiple requests.
+	MaxRequestVectors int `json:"maxRequestVectors" default:"1000" validate:"gt=0"`
+
+	// MaxRequestBytes is the maximum estimated size in bytes of a single
+	// upsert or delete request. Larger batches are split in multiple requests.
+	MaxRequestBytes int `json:"maxRequestBytes" default:"2097152" validate:"gt=0"`
+
+	// UpdateMode is either "upsert", upserting the whole vector of update
+	// records, "partial", only updating the metadata of the vector when
+	// the payload of an update record has no vector values, or the same
+	// values as the payload before, or "update", updating the metadata of
+	// the vector and its values if the payload has any.
+	UpdateMode string `json:"updateMode" default:"upsert" validate:"inclusion=upsert|partial|update"`
+
+	// UpsertMode is either "vectors", upserting the vectors of the records,
+	// or "records", upserting text records into an index with integrated
+	// embedding, which embeds them. Delete records delete vectors either way.
+	UpsertMode string `json:"upsertMode" default:"vectors" validate:"inclusion=vectors|records"`
+
+	// DeleteMode is one of "id", deleting the vector of delete records by ID,
+	// "filter", deleting the vectors matching the metadata filter rendered by
+	// deleteFilter, or "prefix", deleting all the vectors whose ID starts with
+	// the vector ID of the record followed by deletePrefixSeparator.
+	DeleteMode string `json:"deleteMode" default:"id" validate:"inclusion=id|filter|prefix"`
+
+	// DeleteFilter is a [Go template](https://pkg.go.dev/text/template)
+	// executed for each delete record to render a Pinecone metadata filter,
+	// as a JSON object. On top of the functions of the id template, the json
+	// function encodes values. Required by the filter delete mode.
+	DeleteFilter string `json:"deleteFilter"`
+
+	// DeletePrefixSeparator separates the document ID from the chunk in the
+	// vector IDs deleted by the prefix delete mode, like "doc123#chunk0".
+	DeletePrefixSeparator string `json:"deletePrefixSeparator" default:"#"`
+
+	// Retry contains the settings used to retry requests failing with a
+	// transient error, such as an unavailable service or a rate limit.
+	Retry RetryConfig `json:"retry"`
+
+	// RateLimit contains the client side limits of the write requests, so
+	// that writes are smoothed instead of failing when the Pinecone project
+	// is shared.
+	RateLimit RateLimitConfig `json:"rateLimit"`
+
+	// Fields names the fields of the record payload that hold the vector.
+	Fields FieldsConfig `json:"fields"`
+
+	// Metadata configures how the vector metadata is built, on top of the
+	// record metadata.
+	Metadata MetadataConfig `json:"metadata"`
+
+	// Embedding configures the embedding of a text field into the dense
+	// values of the vectors that have none.
+	Embedding EmbeddingConfig `json:"embedding"`
+
+	// Sparse configures the encoding of a text field into the sparse values
+	// of the vectors that have none.
+	Sparse SparseConfig `json:"sparse"`
+
+	// Records configures the text records upserted by the records upsert
+	// mode.
+	Records RecordsConfig `json:"records"`
+}
+
+const (
+	updateModeUpsert  = "upsert"
+	updateModePartial = "partial"
+	updateModeUpdate  = "update"
+)
+
+// writerOptions returns the options of the collection writers, validating the
+// vectors against the index if it's not nil. Each call creates new rate
+// limiters, so it should be called once per destination.
+func (d DestinationConfig) writerOptions(index *indexDescription) (writerOptions, error) {
+	parser, err := newVectorParser(d.Fields, d.Metadata)
+	if err != nil {
+		return writerOptions{}, err
+	}
+	if parser.ids, err = newVectorIDTemplate(d.ID); err != nil {
+		return writerOptions{}, err
+	}
+	if parser.idPolicy, err = newVectorIDPolicy(d.InvalidID); err != nil {
+		return writerOptions{}, err
+	}
+	parser.index = index
+	if parser.embedText, err = newEmbeddingText(d.Embedding, d.Fields); err != nil {
+		return writerOptions{}, err
+	}
+	embedder, err := newEmbedder(d.Embedding, d.Retry, index)
+	if err != nil {
+		return writerOptions{}, err
+	}
+	if parser.sparseEncoder, err = newSparseEncoder(d.Sparse); err != nil {
+		return writerOptions{}, err
+	}
+	if parser.sparseEncoder != nil {
+		if parser.sparseText, err = newTextSource(d.Sparse.Field, ""); err != nil {
+			return writerOptions{}, fmt.Errorf("invalid sparse field: %w", err)
+		}
+	}
+
+	if parser.recordText, err = newRecordsText(d.UpsertMode, d.Records, d.Embedding, d.Sparse); err != nil {
+		return writerOptions{}, err
+	}
+	var records *recordsUpserter
+	if parser.recordText != nil {
+		records, err = newRecordsUpserter(d.APIKey, d.Host, d.TLS, d.Records, d.MaxRequestBytes)
+		if err != nil {
+			return writerOptions{}, err
+		}
+	}
+
+	deleteFilter, err := newDeleteFilterTemplate(d.DeleteMode, d.DeleteFilter)
+	if err != nil {
+		return writerOptions{}, err
+	}
+
+	// the vectors limiter lets the largest request through at once
+	maxVectors := d.MaxRequestVectors
+	if records != nil {
+		maxVectors = max(maxVectors, d.Records.BatchSize)
+	}
+
+	return writerOptions{
+		parser: parser,
+		limits: requestLimits{
+			maxVectors: d.MaxRequestVectors,
+			maxBytes:   d.MaxRequestBytes,
+		},
+		retry:          d.Retry,
+		limiter:        newWriteLimiter(d.RateLimit, maxVectors),
+		partialUpdates: d.UpdateMode == updateModePartial,
+		updateValues:   d.UpdateMode == updateModeUpdate,
+		deleteFilter:   deleteFilter,
+		embedder:       embedder,
+		records:        records,
+
+		deleteByPrefix:        d.DeleteMode == deleteModePrefix,
+		deletePrefixSeparator: d.DeletePrefixSeparator,
+	}, nil
 }
 
 func (d DestinationConfig) toMap() map[string]string {
 	return map[string]string{
-		"apiKey":    d.APIKey,
-		"host":      d.Host,
-		"namespace": d.Namespace,
+		"apiKey":                               d.APIKey,
+		"host":                                 d.Host,
+		"tls.caCertFile":                       d.TLS.CACertFile,
+		"tls.insecureSkipVerify":               fmt.Sprint(d.TLS.InsecureSkipVerify),
+		"indexName":                            d.IndexName,
+		"controlPlaneHost":                     d.ControlPlaneHost,
+		"namespace":                            d.Namespace,
+		"id":                                   d.ID,
+		"invalidID.policy":                     d.InvalidID.Policy,
+		"invalidID.uuidNamespace":              d.InvalidID.UUIDNamespace,
+		"invalidID.originalKeyField":           d.InvalidID.OriginalKeyField,
+		"maxRequestVectors":                    fmt.Sprint(d.MaxRequestVectors),
+		"maxRequestBytes":                      fmt.Sprint(d.MaxRequestBytes),
+		"updateMode":                           d.UpdateMode,
+		"upsertMode":                           d.UpsertMode,
+		"deleteMode":                           d.DeleteMode,
+		"deleteFilter":                         d.DeleteFilter,
+		"deletePrefixSeparator":                d.DeletePrefixSeparator,
+		"retry.maxRetries":                     fmt.Sprint(d.Retry.MaxRetries),
+		"retry.initialBackoff":                 d.Retry.InitialBackoff.String(),
+		"retry.maxBackoff":                     d.Retry.MaxBackoff.String(),
+		"rateLimit.requestsPerSecond":          fmt.Sprint(d.RateLimit.RequestsPerSecond),
+		"rateLimit.vectorsPerSecond":           fmt.Sprint(d.RateLimit.VectorsPerSecond),
+		"rateLimit.namespaceRequestsPerSecond": fmt.Sprint(d.RateLimit.NamespaceRequestsPerSecond),
+		"rateLimit.namespaceVectorsPerSecond":  fmt.Sprint(d.RateLimit.NamespaceVectorsPerSecond),
+		"fields.values":                        d.Fields.Values,
+		"fields.sparseIndices":                 d.Fields.SparseIndices,
+		"fields.sparseValues":                  d.Fields.SparseValues,
+		"fields.metadata":                      d.Fields.Metadata,
+		"fields.required":                      d.Fields.Required,
+		"fields.chunks":                        d.Fields.Chunks,
+		"fields.chunkID":                       d.Fields.ChunkID,
+		"fields.chunkSeparator":                d.Fields.ChunkSeparator,
+		"metadata.payloadFields":               strings.Join(d.Metadata.PayloadFields, ","),
+		"metadata.dropInternal":                fmt.Sprint(d.Metadata.DropInternal),
+		"metadata.include":                     strings.Join(d.Metadata.Include, ","),
+		"metadata.exclude":                     strings.Join(d.Metadata.Exclude, ","),
+		"metadata.rename":                      strings.Join(d.Metadata.Rename, ","),
+		"metadata.coerce":                      strings.Join(d.Metadata.Coerce, ","),
+		"metadata.coerceMode":                  d.Metadata.CoerceMode,
+		"metadata.listDelimiter":               d.Metadata.ListDelimiter,
+		"metadata.maxSize":                     fmt.Sprint(d.Metadata.MaxSize),
+		"metadata.overflowPolicy":              d.Metadata.OverflowPolicy,
+		"metadata.dropKeys":                    strings.Join(d.Metadata.DropKeys, ","),
+		"embedding.provider":                   d.Embedding.Provider,
+		"embedding.field":                      d.Embedding.Field,
+		"embedding.template":                   d.Embedding.Template,
+		"embedding.dimension":                  fmt.Sprint(d.Embedding.Dimension),
+		"embedding.url":                        d.Embedding.URL,
+		"embedding.apiKey":                     d.Embedding.APIKey,
+		"embedding.model":                      d.Embedding.Model,
+		"embedding.batchSize":                  fmt.Sprint(d.Embedding.BatchSize),
+		"embedding.timeout":                    d.Embedding.Timeout.String(),
+		"sparse.encoder":                       d.Sparse.Encoder,
+		"sparse.field":                         d.Sparse.Field,
+		"sparse.statsFile":                     d.Sparse.StatsFile,
+		"sparse.avgDocLength":                  fmt.Sprint(d.Sparse.AvgDocLength),
+		"sparse.b":                             fmt.Sprint(d.Sparse.B),
+		"sparse.k1":                            fmt.Sprint(d.Sparse.K1),
+		"records.field":                        d.Records.Field,
+		"records.indexField":                   d.Records.IndexField,
+		"records.batchSize":                    fmt.Sprint(d.Records.BatchSize),
+		"records.timeout":                      d.Records.Timeout.String(),
 	}
 }
 
@@ -72,32 +298,83 @@ func (d *Destination) Configure(ctx context.Context, cfg config.Config) (err err
 	if err = sdk.Util.ParseConfig(ctx, cfg, &d.config, d.Parameters()); err != nil {
 		return fmt.Errorf("invalid config: %w", err)
 	}
+	if err = validateConnection(d.config.Host, d.config.TLS); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+	if _, err = newVectorParser(d.config.Fields, d.config.Metadata); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+	if _, err = newVectorIDTemplate(d.config.ID); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+	if _, err = newVectorIDPolicy(d.config.InvalidID); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+	if _, err = newDeleteFilterTemplate(d.config.DeleteMode, d.config.DeleteFilter); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+	if _, err = newEmbeddingText(d.config.Embedding, d.config.Fields); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+	if _, err = newSparseEncoder(d.config.Sparse); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+	if _, err = newRecordsText(d.config.UpsertMode, d.config.Records, d.config.Embedding, d.config.Sparse); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+	if d.config.Embedding.Provider == embeddingProviderOpenAI {
+		if _, err = newOpenAIEmbedder(d.config.Embedding, d.config.Retry); err != nil {
+			return fmt.Errorf("invalid config: %w", err)
+		}
+	}
 	sdk.Logger(ctx).Info().Msg("configured pinecone destination")
 
 	return nil
 }
 
 func (d *Destination) Open(ctx context.Context) (err error) {
+	// text records are embedded by the index and never validated against it,
+	// so it's only described when vectors are upserted
+	var index *indexDescription
+	if d.config.UpsertMode != upsertModeRecords {
+		index, err = describeIndex(ctx, describeIndexParams{
+			apiKey:           d.config.APIKey,
+			host:             d.config.Host,
+			tls:              d.config.TLS,
+			name:             d.config.IndexName,
+			controlPlaneHost: d.config.ControlPlaneHost,
+		})
+		if err != nil {
+			return fmt.Errorf("error describing index: %w", err)
+		}
+	}
+
+	opts, err := d.config.writerOptions(index)
+	if err != nil {
+		return err
+	}
+
 	switch {
 	case isGoTextTemplate(d.config.Namespace):
 		template, err := template.New("collection").Parse(d.config.Namespace)
 		if err != nil {
 			return fmt.Errorf("failed to parse namespace template %s: %w", d.config.Namespace, err)
 		}
-		d.colWriter = newMulticollectionWriter(d.config.APIKey, d.config.Host, template)
+		d.colWriter = newMulticollectionWriter(d.config.APIKey, d.config.Host, d.config.TLS, opts, template)
 	case d.config.Namespace == "":
-		d.colWriter = newMulticollectionWriter(d.config.APIKey, d.config.Host, nil)
+		d.colWriter = newMulticollectionWriter(d.config.APIKey, d.config.Host, d.config.TLS, opts, nil)
 	default:
 		index, err := newIndex(ctx, newIndexParams{
 			apiKey:    d.config.APIKey,
 			host:      d.config.Host,
+			tls:       d.config.TLS,
 			namespace: d.config.Namespace,
 		})
 		if err != nil {
 			return fmt.Errorf("error creating a new writer: %w", err)
 		}
 
-		d.colWriter = &singleCollectionWriter{index: index}
+		d.colWriter = &singleCollectionWriter{index: index, opts: opts}
 	}
 
 	sdk.Logger(ctx).Info().Msg("created pinecone destination")
@@ -115,6 +392,11 @@ func (d *Destination) Write(ctx context.Context, records []opencdc.Record) (int,
 }
 
 func (d *Destination) Teardown(_ context.Context) error {
+	if d.colWriter == nil {
+		// Teardown can be called without Open being called first
+		return nil
+	}
+
 	if err := d.colWriter.close(); err != nil {
 		return fmt.Errorf("failed to close index: %w", err)
 	}
@@ -124,6 +406,7 @@ func (d *Destination) Teardown(_ context.Context) error {
 type newIndexParams struct {
 	apiKey    string
 	host      string
+	tls       TLSConfig
 	namespace string
 }
 
@@ -141,29 +424,28 @@ func newIndex(ctx context.Context, params newIndexParams) (*pinecone.IndexConnec
 	}
 	sdk.Logger(ctx).Info().Msg("created pinecone client")
 
-	hostURL, err := url.Parse(params.host)
+	host, err := parseIndexHost(params.host)
 	if err != nil {
-		return nil, fmt.Errorf("invalid host url: %w", err)
+		return nil, err
 	}
 
-	var index *pinecone.IndexConnection
-	if params.namespace != "" {
-		index, err = client.Index(pinecone.NewIndexConnParams{
-			Host:      hostURL.Host,
-			Namespace: params.namespace,
-		})
-		if err != nil {
+	creds, err := params.tls.transportCredentials(host)
+	if err != nil {
+		return nil, err
+	}
+
+	// the credentials override the ones the client derives from the host
+	index, err := client.Index(pinecone.NewIndexConnParams{
+		Host:      host.url(),
+		Namespace: params.namespace,
+	}, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		if params.namespace != "" {
 			return nil, fmt.Errorf(
 				"error establishing index connection to namespace %v: %w",
 				params.namespace, err)
 		}
-	} else {
-		index, err = client.Index(pinecone.NewIndexConnParams{
-			Host: hostURL.Host,
-		})
-		if err != nil {
-			return nil, fmt.Errorf("error establishing index connection: %w", err)
-		}
+		return nil, fmt.Errorf("error establishing index connection: %w", err)
 	}
 	sdk.Logger(ctx).Info().Msg("created pinecone index")
 
@@ -171,6 +453,9 @@ func newIndex(ctx context.Context, params newIndexParams) (*pinecone.IndexConnec
 }
 
 func vectorID(key opencdc.Data) string {
+	if key == nil {
+		return ""
+	}
 	return string(key.Bytes())
 }
 
@@ -182,42 +467,11 @@ type sparseValues struct {
 	Values  []float32 `json:"values"`
 }
 
+// pineconeVectorValues is the payload of a vector. The dense or sparse values
+// are omitted when the vector doesn't have them.
 type pineconeVectorValues struct {
-	Values       []float32    `json:"values"`
-	SparseValues sparseValues `json:"sparse_values,omitempty"`
-}
-
-func parsePineconeVector(rec opencdc.Record) (*pinecone.Vector, error) {
-	id := vectorID(rec.Key)
-
-	var vectorValues pineconeVectorValues
-	err := json.Unmarshal(rec.Payload.After.Bytes(), &vectorValues)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse record json: %w", err)
-	}
-
-	structMap := make(map[string]any)
-	for key, value := range rec.Metadata {
-		structMap[key] = value
-	}
-
-	metadata, err := structpb.NewStruct(structMap)
-	if err != nil {
-		return nil, fmt.Errorf("error protobuf struct: %w", err)
-	}
-
-	vec := &pinecone.Vector{
-		//revive:disable-next-line
-		Id:     id,
-		Values: vectorValues.Values,
-		SparseValues: &pinecone.SparseValues{
-			Indices: vectorValues.SparseValues.Indices,
-			Values:  vectorValues.SparseValues.Values,
-		},
-		Metadata: metadata,
-	}
-
-	return vec, nil
+	Values       []float32     `json:"values,omitempty"`
+	SparseValues *sparseValues `json:"sparse_values,omitempty"`
 }
 
 func isGoTextTemplate(s string) bool {
@@ -19,8 +19,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"math"
-	"net/url"
+	"net/http"
 	"os"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 
@@ -30,15 +32,75 @@ import (
 	"github.com/joho/godotenv"
 	"github.com/matryer/is"
 	"github.com/pinecone-io/go-pinecone/pinecone"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 const maxRetries = 4
 
+// fakeServer is the fake Pinecone server the tests run against, nil when
+// running against a real index.
+var fakeServer *fakePinecone
+
+// liveTests returns whether the tests should run against the real Pinecone
+// index configured through API_KEY and HOST_URL, instead of the fake server.
+func liveTests() bool {
+	return os.Getenv("PINECONE_LIVE_TESTS") == "true"
+}
+
 func destConfigFromEnv(t *testing.T) DestinationConfig {
-	return DestinationConfig{
-		APIKey: requiredEnv(t, "API_KEY"),
-		Host:   requiredEnv(t, "HOST_URL"),
+	cfg := DestinationConfig{
+		MaxRequestVectors: 1000,
+		MaxRequestBytes:   2 << 20,
+		UpdateMode:        updateModeUpsert,
+		UpsertMode:        upsertModeVectors,
+		DeleteMode:        deleteModeID,
+		Retry: RetryConfig{
+			MaxRetries:     5,
+			InitialBackoff: 10 * time.Millisecond,
+			MaxBackoff:     100 * time.Millisecond,
+		},
+		Fields: defaultFieldsConfig,
+		InvalidID: InvalidIDConfig{
+			Policy:        invalidIDPolicyReject,
+			UUIDNamespace: uuid.NameSpaceURL.String(),
+		},
+		Metadata: MetadataConfig{
+			CoerceMode:     coerceModeStrict,
+			ListDelimiter:  ",",
+			MaxSize:        40 << 10,
+			OverflowPolicy: overflowPolicyFail,
+		},
+		Embedding: EmbeddingConfig{
+			Provider:  embeddingProviderNone,
+			Field:     "text",
+			BatchSize: 128,
+			Timeout:   30 * time.Second,
+		},
+		Sparse: SparseConfig{
+			Encoder: sparseEncoderNone,
+			Field:   "text",
+			B:       0.75,
+			K1:      1.2,
+		},
+		Records: RecordsConfig{
+			Field:      "text",
+			IndexField: "chunk_text",
+			BatchSize:  96,
+			Timeout:    30 * time.Second,
+		},
+		ControlPlaneHost: "https://api.pinecone.io",
 	}
+
+	if fakeServer != nil {
+		cfg.APIKey = "fake-api-key"
+		cfg.Host = fakeServer.host()
+	} else {
+		cfg.APIKey = requiredEnv(t, "API_KEY")
+		cfg.Host = requiredEnv(t, "HOST_URL")
+	}
+
+	return cfg
 }
 
 func requiredEnv(t *testing.T, key string) string {
@@ -75,7 +137,7 @@ func TestDestination_NamespaceSet(t *testing.T) {
 
 	vecsToBeWritten := pineconeVectorValues{
 		Values: []float32{1, 2},
-		SparseValues: sparseValues{
+		SparseValues: &sparseValues{
 			Indices: []uint32{3, 5},
 			Values:  []float32{0.5, 0.3},
 		},
@@ -96,21 +158,580 @@ func TestDestination_NamespaceSet(t *testing.T) {
 }
 
 func createIndex(is *is.I, destCfg DestinationConfig) *pinecone.IndexConnection {
-	client, err := pinecone.NewClient(pinecone.NewClientParams{
-		ApiKey: destCfg.APIKey,
+	index, err := newIndex(context.Background(), newIndexParams{
+		apiKey:    destCfg.APIKey,
+		host:      destCfg.Host,
+		tls:       destCfg.TLS,
+		namespace: destCfg.Namespace,
 	})
 	is.NoErr(err)
 
-	hostURL, err := url.Parse(destCfg.Host)
+	return index
+}
+
+func TestDestination_Integration_SplitRequests(t *testing.T) {
+	ctx := context.Background()
+	destCfg := destConfigFromEnv(t)
+	destCfg.Namespace = fmt.Sprintf("test-split%s", uuid.NewString()[:8])
+	destCfg.MaxRequestVectors = 2
+	is := is.New(t)
+	dest := NewDestination()
+
+	is.NoErr(dest.Configure(ctx, destCfg.toMap()))
+	is.NoErr(dest.Open(ctx))
+	defer teardown(ctx, is, dest)
+
+	index := createIndex(is, destCfg)
+	defer deleteAllRecords(is, index)
+
+	recs := nTestRecords(opencdc.OperationCreate, 5)
+
+	// the fourth vector has the wrong dimension, so the records before it are
+	// written in two requests
+	invalid, err := json.Marshal(pineconeVectorValues{Values: []float32{1, 2, 3}})
+	is.NoErr(err)
+	recs[3].Payload.After = opencdc.RawData(invalid)
+
+	written, err := dest.Write(ctx, recs)
+	is.True(err != nil)
+	is.Equal(written, 3) // only the records before the invalid one were written
+
+	for _, rec := range recs[:3] {
+		assertWrittenRecordIndex(ctx, t, is, index, string(rec.Key.Bytes()), mustParseVectorValues(is, rec))
+	}
+}
+
+func TestDestination_Integration_SplitRequests_FailedRequest(t *testing.T) {
+	if fakeServer == nil {
+		t.Skip("error injection requires the fake Pinecone server")
+	}
+
+	ctx := context.Background()
+	destCfg := destConfigFromEnv(t)
+	destCfg.Namespace = fmt.Sprintf("test-split%s", uuid.NewString()[:8])
+	destCfg.MaxRequestVectors = 2
+	is := is.New(t)
+	dest := NewDestination()
+
+	is.NoErr(dest.Configure(ctx, destCfg.toMap()))
+	is.NoErr(dest.Open(ctx))
+	defer teardown(ctx, is, dest)
+
+	index := createIndex(is, destCfg)
+	defer deleteAllRecords(is, index)
+
+	recs := nTestRecords(opencdc.OperationCreate, 5)
+
+	// the records are valid, but Pinecone rejects the second request
+	fakeServer.resetCalls()
+	fakeServer.failNext("Upsert", nil, status.Error(codes.InvalidArgument, "invalid"))
+
+	written, err := dest.Write(ctx, recs)
+	is.True(err != nil)
+	is.Equal(written, 2) // only the first request was written
+	is.Equal(fakeServer.callCount("Upsert"), 2)
+
+	for _, rec := range recs[:2] {
+		assertWrittenRecordIndex(ctx, t, is, index, string(rec.Key.Bytes()), mustParseVectorValues(is, rec))
+	}
+}
+
+func TestDestination_Integration_InvalidVector(t *testing.T) {
+	ctx := context.Background()
+	destCfg := destConfigFromEnv(t)
+	destCfg.Namespace = fmt.Sprintf("test-invalid%s", uuid.NewString()[:8])
+	is := is.New(t)
+	dest := NewDestination()
+
+	is.NoErr(dest.Configure(ctx, destCfg.toMap()))
+	is.NoErr(dest.Open(ctx))
+	defer teardown(ctx, is, dest)
+
+	index := createIndex(is, destCfg)
+	defer deleteAllRecords(is, index)
+
+	recs := nTestRecords(opencdc.OperationCreate, 3)
+
+	// the index has a dimension of 2
+	invalid, err := json.Marshal(pineconeVectorValues{Values: []float32{1, 2, 3}})
+	is.NoErr(err)
+	recs[1].Payload.After = opencdc.RawData(invalid)
+
+	written, err := dest.Write(ctx, recs)
+	is.True(err != nil)
+	is.True(strings.Contains(err.Error(), "doesn't match the index dimension 2"))
+	is.Equal(written, 1) // only the records before the invalid one are written
+
+	assertWrittenRecordIndex(ctx, t, is, index, string(recs[0].Key.Bytes()), mustParseVectorValues(is, recs[0]))
+}
+
+func TestDestination_Integration_SparseIndex(t *testing.T) {
+	if fakeServer == nil {
+		t.Skip("the sparse index requires the fake Pinecone server")
+	}
+
+	ctx := context.Background()
+	is := is.New(t)
+
+	// a dimension of zero makes a sparse index
+	sparseServer, err := newFakePinecone(0)
 	is.NoErr(err)
+	defer sparseServer.stop()
+
+	destCfg := destConfigFromEnv(t)
+	destCfg.Host = sparseServer.host()
+	destCfg.IndexName = "sparse"
+	destCfg.ControlPlaneHost = sparseServer.controlPlane(t, destCfg.IndexName, metricDotProduct)
+	destCfg.Namespace = "test-sparse"
+	destCfg.Fields.Required = requiredSparse
+
+	dest := NewDestination()
+	is.NoErr(dest.Configure(ctx, destCfg.toMap()))
+	is.NoErr(dest.Open(ctx))
+	defer teardown(ctx, is, dest)
 
-	index, err := client.Index(pinecone.NewIndexConnParams{
-		Host:      hostURL.Hostname(),
-		Namespace: destCfg.Namespace,
+	payload, err := json.Marshal(pineconeVectorValues{
+		SparseValues: &sparseValues{Indices: []uint32{3, 5}, Values: []float32{0.5, 0.3}},
 	})
 	is.NoErr(err)
 
-	return index
+	recs := nTestRecords(opencdc.OperationCreate, 2)
+	for i := range recs {
+		recs[i].Payload.After = opencdc.RawData(payload)
+	}
+
+	written, err := dest.Write(ctx, recs)
+	is.NoErr(err)
+	is.Equal(written, len(recs))
+
+	// dense values are rejected before being sent
+	_, err = dest.Write(ctx, nTestRecords(opencdc.OperationCreate, 1))
+	is.True(err != nil)
+
+	index := createIndex(is, destCfg)
+	defer index.Close()
+
+	res, err := index.FetchVectors(ctx, []string{string(recs[0].Key.Bytes())})
+	is.NoErr(err)
+
+	vec := res.Vectors[string(recs[0].Key.Bytes())]
+	is.True(vec != nil)
+	is.Equal(len(vec.Values), 0)
+	is.Equal(vec.SparseValues.Indices, []uint32{3, 5})
+}
+
+func TestDestination_Integration_PartialUpdates(t *testing.T) {
+	if fakeServer == nil {
+		t.Skip("reading the update back right away requires the fake Pinecone server")
+	}
+
+	ctx := context.Background()
+	destCfg := destConfigFromEnv(t)
+	destCfg.Namespace = fmt.Sprintf("test-partial%s", uuid.NewString()[:8])
+	destCfg.UpdateMode = updateModePartial
+	is := is.New(t)
+	dest := NewDestination()
+
+	is.NoErr(dest.Configure(ctx, destCfg.toMap()))
+	is.NoErr(dest.Open(ctx))
+	defer teardown(ctx, is, dest)
+
+	index := createIndex(is, destCfg)
+	defer deleteAllRecords(is, index)
+
+	rec := nTestRecords(opencdc.OperationCreate, 1)[0]
+	rec.Metadata = opencdc.Metadata{"title": "hello", "year": "2024"}
+
+	update := rec.Clone()
+	update.Operation = opencdc.OperationUpdate
+	update.Metadata = opencdc.Metadata{"title": "updated"}
+	update.Payload.After = opencdc.RawData(`{}`)
+
+	written, err := dest.Write(ctx, []opencdc.Record{rec, update})
+	is.NoErr(err)
+	is.Equal(written, 2)
+
+	id := string(rec.Key.Bytes())
+	res, err := index.FetchVectors(ctx, []string{id})
+	is.NoErr(err)
+
+	vec := res.Vectors[id]
+	is.True(vec != nil)
+	is.Equal(vec.Values, mustParseVectorValues(is, rec).Values) // values are kept
+	is.Equal(vec.Metadata.AsMap(), map[string]any{"title": "updated", "year": "2024"})
+}
+
+func TestDestination_Integration_UpdateValues(t *testing.T) {
+	if fakeServer == nil {
+		t.Skip("reading the update back right away requires the fake Pinecone server")
+	}
+
+	ctx := context.Background()
+	destCfg := destConfigFromEnv(t)
+	destCfg.Namespace = fmt.Sprintf("test-update%s", uuid.NewString()[:8])
+	destCfg.UpdateMode = updateModeUpdate
+	is := is.New(t)
+	dest := NewDestination()
+
+	is.NoErr(dest.Configure(ctx, destCfg.toMap()))
+	is.NoErr(dest.Open(ctx))
+	defer teardown(ctx, is, dest)
+
+	index := createIndex(is, destCfg)
+	defer deleteAllRecords(is, index)
+
+	rec := nTestRecords(opencdc.OperationCreate, 1)[0]
+	rec.Metadata = opencdc.Metadata{"title": "hello", "year": "2024"}
+
+	update := rec.Clone()
+	update.Operation = opencdc.OperationUpdate
+	update.Metadata = opencdc.Metadata{"title": "updated"}
+	update.Payload.After = opencdc.RawData(`{"values": [3, 4]}`)
+
+	written, err := dest.Write(ctx, []opencdc.Record{rec, update})
+	is.NoErr(err)
+	is.Equal(written, 2)
+
+	id := string(rec.Key.Bytes())
+	res, err := index.FetchVectors(ctx, []string{id})
+	is.NoErr(err)
+
+	vec := res.Vectors[id]
+	is.True(vec != nil)
+	is.Equal(vec.Values, []float32{3, 4})
+	is.Equal(vec.Metadata.AsMap(), map[string]any{"title": "updated", "year": "2024"})
+}
+
+func TestDestination_Integration_DeleteByFilter(t *testing.T) {
+	if fakeServer == nil {
+		t.Skip("reading the deletes back right away requires the fake Pinecone server")
+	}
+
+	ctx := context.Background()
+	destCfg := destConfigFromEnv(t)
+	destCfg.Namespace = fmt.Sprintf("test-filter%s", uuid.NewString()[:8])
+	destCfg.DeleteMode = deleteModeFilter
+	destCfg.DeleteFilter = `{"doc_id": {{ json .Key.doc }}}`
+	is := is.New(t)
+	dest := NewDestination()
+
+	is.NoErr(dest.Configure(ctx, destCfg.toMap()))
+	is.NoErr(dest.Open(ctx))
+	defer teardown(ctx, is, dest)
+
+	index := createIndex(is, destCfg)
+	defer deleteAllRecords(is, index)
+
+	chunks := nTestRecords(opencdc.OperationCreate, 4)
+	for i, doc := range []string{"a", "a", "b", "a"} {
+		chunks[i].Metadata = opencdc.Metadata{"doc_id": doc}
+	}
+	deleteDocA := opencdc.Record{
+		Operation: opencdc.OperationDelete,
+		Key:       opencdc.StructuredData{"doc": "a"},
+	}
+
+	// the last chunk is written after the delete, so it's kept
+	records := []opencdc.Record{chunks[0], chunks[1], chunks[2], deleteDocA, chunks[3]}
+	written, err := dest.Write(ctx, records)
+	is.NoErr(err)
+	is.Equal(written, len(records))
+
+	ids := make([]string, len(chunks))
+	for i, rec := range chunks {
+		ids[i] = string(rec.Key.Bytes())
+	}
+	res, err := index.FetchVectors(ctx, ids)
+	is.NoErr(err)
+
+	is.Equal(len(res.Vectors), 2)
+	is.True(res.Vectors[ids[2]] != nil)
+	is.True(res.Vectors[ids[3]] != nil)
+}
+
+func TestDestination_Integration_DeleteByPrefix(t *testing.T) {
+	if fakeServer == nil {
+		t.Skip("reading the deletes back right away requires the fake Pinecone server")
+	}
+
+	ctx := context.Background()
+	destCfg := destConfigFromEnv(t)
+	destCfg.Namespace = fmt.Sprintf("test-prefix%s", uuid.NewString()[:8])
+	destCfg.DeleteMode = deleteModePrefix
+	destCfg.DeletePrefixSeparator = "#"
+	// the chunks span several pages and delete requests
+	destCfg.MaxRequestVectors = 3
+	is := is.New(t)
+	dest := NewDestination()
+
+	is.NoErr(dest.Configure(ctx, destCfg.toMap()))
+	is.NoErr(dest.Open(ctx))
+	defer teardown(ctx, is, dest)
+
+	index := createIndex(is, destCfg)
+	defer deleteAllRecords(is, index)
+
+	keys := []string{"doc1", "doc1#chunk0", "doc10#chunk0", "doc2#chunk0"}
+	for i := range 7 {
+		keys = append(keys, fmt.Sprintf("doc1#chunk%d", i+1))
+	}
+	chunks := nTestRecords(opencdc.OperationCreate, len(keys))
+	for i, key := range keys {
+		chunks[i].Key = opencdc.RawData(key)
+	}
+
+	written, err := dest.Write(ctx, chunks)
+	is.NoErr(err)
+	is.Equal(written, len(chunks))
+
+	written, err = dest.Write(ctx, []opencdc.Record{{
+		Operation: opencdc.OperationDelete,
+		Key:       opencdc.RawData("doc1"),
+	}})
+	is.NoErr(err)
+	is.Equal(written, 1)
+
+	res, err := index.FetchVectors(ctx, keys)
+	is.NoErr(err)
+
+	remaining := make([]string, 0, len(res.Vectors))
+	for id := range res.Vectors {
+		remaining = append(remaining, id)
+	}
+	slices.Sort(remaining)
+	is.Equal(remaining, []string{"doc1", "doc10#chunk0", "doc2#chunk0"})
+}
+
+func TestDestination_Integration_Chunks(t *testing.T) {
+	if fakeServer == nil {
+		t.Skip("reading the stale chunks back right away requires the fake Pinecone server")
+	}
+
+	ctx := context.Background()
+	destCfg := destConfigFromEnv(t)
+	destCfg.Namespace = fmt.Sprintf("test-chunks%s", uuid.NewString()[:8])
+	destCfg.Fields.Chunks = "chunks"
+	destCfg.DeleteMode = deleteModePrefix
+	// the stale chunks span several pages and delete requests
+	destCfg.MaxRequestVectors = 3
+	is := is.New(t)
+	dest := NewDestination()
+
+	is.NoErr(dest.Configure(ctx, destCfg.toMap()))
+	is.NoErr(dest.Open(ctx))
+	defer teardown(ctx, is, dest)
+
+	index := createIndex(is, destCfg)
+	defer deleteAllRecords(is, index)
+
+	chunksRecord := func(op opencdc.Operation, key string, n int) opencdc.Record {
+		chunks := make([]any, n)
+		for i := range chunks {
+			chunks[i] = map[string]any{"values": []float32{float32(i), 1}}
+		}
+		payload, err := json.Marshal(map[string]any{"chunks": chunks})
+		is.NoErr(err)
+		return opencdc.Record{
+			Operation: op,
+			Key:       opencdc.RawData(key),
+			Payload:   opencdc.Change{After: opencdc.RawData(payload)},
+		}
+	}
+	fetchIDs := func(ids []string) []string {
+		res, err := index.FetchVectors(ctx, ids)
+		is.NoErr(err)
+
+		found := make([]string, 0, len(res.Vectors))
+		for id := range res.Vectors {
+			found = append(found, id)
+		}
+		slices.Sort(found)
+		return found
+	}
+
+	var allIDs []string
+	for i := range 12 {
+		allIDs = append(allIDs, fmt.Sprintf("doc1#%d", i), fmt.Sprintf("doc10#%d", i))
+	}
+
+	written, err := dest.Write(ctx, []opencdc.Record{
+		chunksRecord(opencdc.OperationCreate, "doc1", 12),
+		chunksRecord(opencdc.OperationCreate, "doc10", 2),
+	})
+	is.NoErr(err)
+	is.Equal(written, 2)
+	is.Equal(len(fetchIDs(allIDs)), 14)
+
+	written, err = dest.Write(ctx, []opencdc.Record{chunksRecord(opencdc.OperationUpdate, "doc1", 2)})
+	is.NoErr(err)
+	is.Equal(written, 1)
+	is.Equal(fetchIDs(allIDs), []string{"doc1#0", "doc1#1", "doc10#0", "doc10#1"})
+
+	// the chunks of a deleted document are deleted by prefix
+	written, err = dest.Write(ctx, []opencdc.Record{{
+		Operation: opencdc.OperationDelete,
+		Key:       opencdc.RawData("doc1"),
+	}})
+	is.NoErr(err)
+	is.Equal(written, 1)
+	is.Equal(fetchIDs(allIDs), []string{"doc10#0", "doc10#1"})
+}
+
+func TestDestination_Integration_LocalEmbedding(t *testing.T) {
+	ctx := context.Background()
+	destCfg := destConfigFromEnv(t)
+	destCfg.Namespace = fmt.Sprintf("test-embedding%s", uuid.NewString()[:8])
+	destCfg.Embedding.Provider = embeddingProviderLocal
+	is := is.New(t)
+	dest := NewDestination()
+
+	is.NoErr(dest.Configure(ctx, destCfg.toMap()))
+	is.NoErr(dest.Open(ctx))
+	defer teardown(ctx, is, dest)
+
+	index := createIndex(is, destCfg)
+	defer deleteAllRecords(is, index)
+
+	written, err := dest.Write(ctx, []opencdc.Record{
+		{
+			Operation: opencdc.OperationCreate,
+			Key:       opencdc.RawData("doc1"),
+			Payload:   opencdc.Change{After: opencdc.RawData(`{"text": "a document about foxes"}`)},
+		},
+		{
+			Operation: opencdc.OperationCreate,
+			Key:       opencdc.RawData("doc2"),
+			Payload:   opencdc.Change{After: opencdc.RawData(`{"values": [1, 2]}`)},
+		},
+	})
+	is.NoErr(err)
+	is.Equal(written, 2)
+
+	res, err := index.FetchVectors(ctx, []string{"doc1", "doc2"})
+	is.NoErr(err)
+	is.Equal(len(res.Vectors), 2)
+
+	// the dimension of the embeddings defaults to the index dimension
+	is.Equal(len(res.Vectors["doc1"].Values), len(res.Vectors["doc2"].Values))
+	is.Equal(res.Vectors["doc2"].Values, []float32{1, 2})
+}
+
+func TestDestination_Integration_OpenAIEmbedding(t *testing.T) {
+	ctx := context.Background()
+	embeddingServer := newFakeEmbeddingServer(t)
+	// the first request is retried
+	embeddingServer.failures = []int{http.StatusServiceUnavailable}
+
+	destCfg := destConfigFromEnv(t)
+	destCfg.Namespace = fmt.Sprintf("test-openai%s", uuid.NewString()[:8])
+	destCfg.Embedding.Provider = embeddingProviderOpenAI
+	destCfg.Embedding.URL = embeddingServer.URL + "/v1"
+	destCfg.Embedding.Model = "test-model"
+	destCfg.Embedding.Template = `{{ .Payload.After.title }}`
+	is := is.New(t)
+	dest := NewDestination()
+
+	is.NoErr(dest.Configure(ctx, destCfg.toMap()))
+	is.NoErr(dest.Open(ctx))
+	defer teardown(ctx, is, dest)
+
+	index := createIndex(is, destCfg)
+	defer deleteAllRecords(is, index)
+
+	recs := []opencdc.Record{
+		{
+			Operation: opencdc.OperationCreate,
+			Key:       opencdc.RawData("doc1"),
+			Payload:   opencdc.Change{After: opencdc.RawData(`{"title": "a"}`)},
+		},
+		{
+			Operation: opencdc.OperationCreate,
+			Key:       opencdc.RawData("doc2"),
+			Payload:   opencdc.Change{After: opencdc.RawData(`{"values": [7, 7]}`)},
+		},
+		{
+			Operation: opencdc.OperationCreate,
+			Key:       opencdc.RawData("doc3"),
+			Payload:   opencdc.Change{After: opencdc.RawData(`{"title": "ccc"}`)},
+		},
+	}
+	written, err := dest.Write(ctx, recs)
+	is.NoErr(err)
+	is.Equal(written, 3)
+
+	// the texts of the upsert batch are embedded by a single request, sent
+	// twice
+	is.Equal(len(embeddingServer.requests), 2)
+	is.Equal(embeddingServer.requests[1].Input, []string{"a", "ccc"})
+
+	res, err := index.FetchVectors(ctx, []string{"doc1", "doc2", "doc3"})
+	is.NoErr(err)
+	is.Equal(res.Vectors["doc1"].Values, []float32{1, 0})
+	is.Equal(res.Vectors["doc2"].Values, []float32{7, 7})
+	is.Equal(res.Vectors["doc3"].Values, []float32{3, 1})
+}
+
+func TestDestination_Integration_SparseEncoding(t *testing.T) {
+	if fakeServer == nil {
+		t.Skip("the sparse index requires the fake Pinecone server")
+	}
+
+	ctx := context.Background()
+	is := is.New(t)
+
+	sparseServer, err := newFakePinecone(0)
+	is.NoErr(err)
+	defer sparseServer.stop()
+
+	destCfg := destConfigFromEnv(t)
+	destCfg.Host = sparseServer.host()
+	destCfg.IndexName = "sparse"
+	destCfg.ControlPlaneHost = sparseServer.controlPlane(t, destCfg.IndexName, metricDotProduct)
+	destCfg.Namespace = "test-bm25"
+	destCfg.Fields.Required = requiredSparse
+	destCfg.Sparse.Encoder = sparseEncoderBM25
+	destCfg.Sparse.AvgDocLength = 2
+
+	dest := NewDestination()
+	is.NoErr(dest.Configure(ctx, destCfg.toMap()))
+	is.NoErr(dest.Open(ctx))
+	defer teardown(ctx, is, dest)
+
+	written, err := dest.Write(ctx, []opencdc.Record{
+		{
+			Operation: opencdc.OperationCreate,
+			Key:       opencdc.RawData("doc1"),
+			Payload:   opencdc.Change{After: opencdc.RawData(`{"text": "The foxes are running"}`)},
+		},
+		{
+			Operation: opencdc.OperationCreate,
+			Key:       opencdc.RawData("doc2"),
+			Payload: opencdc.Change{After: opencdc.RawData(
+				`{"text": "ignored", "sparse_values": {"indices": [3], "values": [0.5]}}`,
+			)},
+		},
+	})
+	is.NoErr(err)
+	is.Equal(written, 2)
+
+	index := createIndex(is, destCfg)
+	defer index.Close()
+
+	res, err := index.FetchVectors(ctx, []string{"doc1", "doc2"})
+	is.NoErr(err)
+
+	want := []uint32{murmur3([]byte("fox")), murmur3([]byte("run"))}
+	slices.Sort(want)
+	is.Equal(res.Vectors["doc1"].SparseValues.Indices, want)
+
+	// sparse values of the record aren't encoded again
+	is.Equal(res.Vectors["doc2"].SparseValues.Indices, []uint32{3})
+}
+
+func mustParseVectorValues(is *is.I, rec opencdc.Record) pineconeVectorValues {
+	var values pineconeVectorValues
+	is.NoErr(json.Unmarshal(rec.Payload.After.Bytes(), &values))
+	return values
 }
 
 func TestDestination_Integration_WriteDelete(t *testing.T) {
@@ -132,7 +753,7 @@ func TestDestination_Integration_WriteDelete(t *testing.T) {
 
 	vecsToBeWritten := pineconeVectorValues{
 		Values: []float32{1, 2},
-		SparseValues: sparseValues{
+		SparseValues: &sparseValues{
 			Indices: []uint32{3, 5},
 			Values:  []float32{0.5, 0.3},
 		},
@@ -262,9 +883,105 @@ func TestMain(t *testing.M) {
 			Msg("failed to load env variables from .env file, assuming github ci has the required env vars")
 	}
 
-	os.Exit(t.Run())
+	if !liveTests() {
+		var err error
+		fakeServer, err = newFakePinecone(2)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	code := t.Run()
+	if fakeServer != nil {
+		fakeServer.stop()
+	}
+	os.Exit(code)
 }
 
 func teardown(ctx context.Context, is *is.I, dest sdk.Destination) {
 	is.NoErr(dest.Teardown(ctx))
 }
+
+func TestDestination_Integration_Retries(t *testing.T) {
+	if fakeServer == nil {
+		t.Skip("error injection requires the fake Pinecone server")
+	}
+
+	ctx := context.Background()
+	destCfg := destConfigFromEnv(t)
+	destCfg.Namespace = fmt.Sprintf("test-retries%s", uuid.NewString()[:8])
+	destCfg.MaxRequestVectors = 2
+	destCfg.Retry.MaxRetries = 2
+
+	dest := NewDestination()
+	is := is.New(t)
+	is.NoErr(dest.Configure(ctx, destCfg.toMap()))
+	is.NoErr(dest.Open(ctx))
+	defer teardown(ctx, is, dest)
+
+	index := createIndex(is, destCfg)
+	defer deleteAllRecords(is, index)
+
+	t.Run("retries transient errors", func(t *testing.T) {
+		is := is.New(t)
+		fakeServer.resetCalls()
+
+		recs := nTestRecords(opencdc.OperationCreate, 3)
+		fakeServer.failNext("Upsert",
+			status.Error(codes.Unavailable, "unavailable"),
+			status.Error(codes.ResourceExhausted, "rate limited"),
+		)
+
+		written, err := dest.Write(ctx, recs)
+		is.NoErr(err)
+		is.Equal(written, len(recs))
+
+		// 2 requests, the first one retried twice
+		is.Equal(fakeServer.callCount("Upsert"), 4)
+	})
+
+	t.Run("gives up once retries are exhausted", func(t *testing.T) {
+		is := is.New(t)
+		fakeServer.resetCalls()
+
+		recs := nTestRecords(opencdc.OperationDelete, 3)
+		fakeServer.failNext("Delete",
+			status.Error(codes.Unavailable, "unavailable"),
+			status.Error(codes.Unavailable, "unavailable"),
+			status.Error(codes.Unavailable, "unavailable"),
+		)
+
+		written, err := dest.Write(ctx, recs)
+		is.True(err != nil)
+		is.Equal(written, 0)
+		is.Equal(fakeServer.callCount("Delete"), 3)
+	})
+
+	t.Run("fails immediately on non retryable errors", func(t *testing.T) {
+		is := is.New(t)
+		fakeServer.resetCalls()
+
+		recs := nTestRecords(opencdc.OperationCreate, 3)
+		fakeServer.failNext("Upsert",
+			nil, // first request succeeds
+			status.Error(codes.InvalidArgument, "invalid"),
+		)
+
+		written, err := dest.Write(ctx, recs)
+		is.True(err != nil)
+		is.Equal(written, 2)
+		is.Equal(fakeServer.callCount("Upsert"), 2)
+
+		// the error contains the IDs of the failed request
+		is.True(strings.Contains(err.Error(), string(recs[2].Key.Bytes())))
+		is.True(!strings.Contains(err.Error(), string(recs[0].Key.Bytes())))
+	})
+}
+
+func nTestRecords(op opencdc.Operation, n int) []opencdc.Record {
+	var recs []opencdc.Record
+	for len(recs) < n {
+		recs = append(recs, testRecords(op)...)
+	}
+	return recs[:n]
+}
@@ -15,6 +15,7 @@
 package pinecone
 
 import (
+	"context"
 	"encoding/json"
 	"testing"
 
@@ -22,12 +23,12 @@ import (
 	"github.com/matryer/is"
 )
 
-func TestParsePineconeVector(t *testing.T) {
+func TestVectorParser_DefaultFields(t *testing.T) {
 	is := is.New(t)
 
 	vecToBeWritten := pineconeVectorValues{
 		Values: []float32{1, 2},
-		SparseValues: sparseValues{
+		SparseValues: &sparseValues{
 			Indices: []uint32{3, 5},
 			Values:  []float32{0.5, 0.3},
 		},
@@ -50,7 +51,7 @@ func TestParsePineconeVector(t *testing.T) {
 		},
 	}
 
-	vec, err := parsePineconeVector(rec)
+	vec, err := defaultVectorParser.parse(context.Background(), rec)
 	is.NoErr(err)
 
 	is.Equal(vec.Id, "key1")
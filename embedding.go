@@ -0,0 +1,266 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pinecone
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strings"
+	"text/template"
+	"time"
+	"unicode"
+
+	"github.com/conduitio/conduit-commons/opencdc"
+)
+
+const (
+	embeddingProviderNone   = "none"
+	embeddingProviderLocal  = "local"
+	embeddingProviderOpenAI = "openai"
+)
+
+// EmbeddingConfig configures the embedding of a text field into the dense
+// values of the vectors that have none.
+type EmbeddingConfig struct {
+	// Provider is one of "none", disabling embeddings, "local", embedding
+	// texts with a deterministic feature hashing model that needs no GPU nor
+	// network access, or "openai", calling an OpenAI compatible embeddings
+	// API.
+	Provider string `json:"provider" default:"none" validate:"inclusion=none|local|openai"`
+
+	// Field is the field holding the text to embed, as a dot separated path
+	// or a JSON pointer. It's read within each chunk if records are fanned
+	// out into chunks.
+	Field string `json:"field" default:"text"`
+
+	// Template is a [Go template](https://pkg.go.dev/text/template) executed
+	// for each record to render the text to embed, instead of reading field.
+	// It can't be used with chunks.
+	Template string `json:"template"`
+
+	// Dimension is the dimension of the embeddings. The local embeddings
+	// default to the dimension of the index if zero, while the openai
+	// provider only sends it to the API if set.
+	Dimension int `json:"dimension" validate:"gt=-1"`
+
+	// URL is the base URL of the OpenAI compatible API, like
+	// "https://api.openai.com/v1", to which "/embeddings" is appended.
+	// Required by the openai provider.
+	URL string `json:"url"`
+
+	// APIKey is sent as a bearer token to the OpenAI compatible API, if set.
+	APIKey string `json:"apiKey"`
+
+	// Model is the embedding model of the OpenAI compatible API. Required by
+	// the openai provider.
+	Model string `json:"model"`
+
+	// BatchSize is the maximum number of texts embedded by a single request
+	// to the OpenAI compatible API. The texts of larger batches are embedded
+	// in multiple requests.
+	BatchSize int `json:"batchSize" default:"128" validate:"gt=0"`
+
+	// Timeout is the timeout of a single request to the OpenAI compatible
+	// API. Requests timing out are retried like failed writes.
+	Timeout time.Duration `json:"timeout" default:"30s"`
+}
+
+func (c EmbeddingConfig) enabled() bool {
+	return c.Provider != "" && c.Provider != embeddingProviderNone
+}
+
+// Embedder turns texts into the dense values of vectors.
+type Embedder interface {
+	// Embed returns the dense values of each text, in order.
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// newEmbedder returns the embedder of the provider, nil if embeddings are
+// disabled. The dimension of the index, if it's not nil, is the default
+// dimension of the local embeddings. Requests to embedding APIs are retried
+// according to the retry config.
+func newEmbedder(cfg EmbeddingConfig, retry RetryConfig, index *indexDescription) (Embedder, error) {
+	if !cfg.enabled() {
+		return nil, nil
+	}
+	if index != nil && index.vectorType == vectorTypeSparse {
+		return nil, errors.New("sparse indexes have no dense values to embed texts into")
+	}
+
+	switch cfg.Provider {
+	case embeddingProviderLocal:
+		dimension := cfg.Dimension
+		if dimension == 0 && index != nil {
+			dimension = index.dimension
+		}
+		if dimension <= 0 {
+			return nil, errors.New("embedding.dimension is required when the index dimension is unknown")
+		}
+		return &hashEmbedder{dimension: dimension}, nil
+	case embeddingProviderOpenAI:
+		return newOpenAIEmbedder(cfg, retry)
+	default:
+		return nil, fmt.Errorf("unknown embedding provider %q", cfg.Provider)
+	}
+}
+
+// textSource reads the text to encode into a vector out of either a field or
+// a template.
+type textSource struct {
+	// field is nil if the text is rendered by the template.
+	field    fieldPath
+	template *template.Template
+}
+
+func newTextSource(field, tmpl string) (*textSource, error) {
+	if tmpl != "" {
+		t, err := template.New("text").Funcs(vectorIDFuncs).Option("missingkey=error").Parse(tmpl)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse template %s: %w", tmpl, err)
+		}
+		return &textSource{template: t}, nil
+	}
+
+	path, err := parseFieldPath(field)
+	if err != nil {
+		return nil, err
+	}
+	return &textSource{field: path}, nil
+}
+
+// newEmbeddingText returns nil if embeddings are disabled. Templates are
+// rendered once per record, so they can't be used with chunks.
+func newEmbeddingText(cfg EmbeddingConfig, fields FieldsConfig) (*textSource, error) {
+	if !cfg.enabled() {
+		return nil, nil //nolint:nilnil // vectors aren't embedded
+	}
+	if cfg.Template != "" && fields.Chunks != "" {
+		return nil, errors.New("embedding.template can't be used with fields.chunks, use embedding.field instead")
+	}
+
+	text, err := newTextSource(cfg.Field, cfg.Template)
+	if err != nil {
+		return nil, fmt.Errorf("invalid embedding text: %w", err)
+	}
+	return text, nil
+}
+
+// text returns the text out of the record, or the field of the object holding
+// the vector fields, which is either the payload or a chunk. Texts without
+// words, like "...", are rejected here so that they fail their own record
+// rather than the embedding of the whole batch.
+func (t *textSource) text(rec opencdc.Record, obj any) (string, error) {
+	var text string
+	if t.template != nil {
+		// the parsed payload replaces raw data, so that its fields can be
+		// used like {{ .Payload.After.title }}
+		if payload, ok := obj.(map[string]any); ok {
+			rec.Payload.After = opencdc.StructuredData(payload)
+		}
+
+		var sb strings.Builder
+		if err := t.template.Execute(&sb, rec); err != nil {
+			return "", fmt.Errorf("failed to execute text template: %w", err)
+		}
+		text = sb.String()
+	} else {
+		value, ok := t.field.get(obj)
+		if !ok {
+			return "", fmt.Errorf("record has no text field %q", t.field)
+		}
+		if text, ok = value.(string); !ok {
+			return "", fmt.Errorf("text field %q is a %T, expected a string", t.field, value)
+		}
+	}
+
+	if len(tokenize(text)) == 0 {
+		return "", errors.New("text has no words")
+	}
+	return text, nil
+}
+
+// hashEmbedder embeds texts with feature hashing: the words and pairs of
+// consecutive words of a text are hashed into signed buckets of the vector,
+// which is then normalized. Texts sharing words get similar vectors, and the
+// same text always gets the same vector.
+type hashEmbedder struct {
+	dimension int
+}
+
+func (e *hashEmbedder) Embed(_ context.Context, texts []string) ([][]float32, error) {
+	embeddings := make([][]float32, len(texts))
+	for i, text := range texts {
+		values, err := e.embed(text)
+		if err != nil {
+			return nil, fmt.Errorf("text %d: %w", i, err)
+		}
+		embeddings[i] = values
+	}
+	return embeddings, nil
+}
+
+func (e *hashEmbedder) embed(text string) ([]float32, error) {
+	words := tokenize(text)
+	if len(words) == 0 {
+		return nil, errors.New("text has no words to embed")
+	}
+
+	values := make([]float64, e.dimension)
+	for i, word := range words {
+		e.add(values, word)
+		if i > 0 {
+			e.add(values, words[i-1]+" "+word)
+		}
+	}
+
+	var norm float64
+	for _, v := range values {
+		norm += v * v
+	}
+	norm = math.Sqrt(norm)
+	if norm == 0 {
+		return nil, errors.New("text features cancel out into a zero vector")
+	}
+
+	embedding := make([]float32, e.dimension)
+	for i, v := range values {
+		embedding[i] = float32(v / norm)
+	}
+	return embedding, nil
+}
+
+// add hashes the feature into a bucket of the values, with the sign given by
+// the top bit of the hash so that collisions cancel out on average.
+func (e *hashEmbedder) add(values []float64, feature string) {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(feature))
+	sum := h.Sum64()
+
+	sign := 1.0
+	if sum>>63 == 1 {
+		sign = -1
+	}
+	values[sum%uint64(e.dimension)] += sign
+}
+
+// tokenize splits the text into lower case words, made of letters and digits.
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
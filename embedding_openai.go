@@ -0,0 +1,157 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pinecone
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// openAIEmbedder embeds texts with an OpenAI compatible embeddings API, like
+// the OpenAI API itself or a self-hosted embedding server.
+type openAIEmbedder struct {
+	client     *http.Client
+	endpoint   string
+	apiKey     string
+	model      string
+	dimensions int
+	batchSize  int
+	timeout    time.Duration
+	retry      RetryConfig
+}
+
+func newOpenAIEmbedder(cfg EmbeddingConfig, retry RetryConfig) (*openAIEmbedder, error) {
+	if cfg.URL == "" {
+		return nil, errors.New("embedding.url is required by the openai provider")
+	}
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid embedding url %q: %w", cfg.URL, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid embedding url %q: the scheme must be http or https", cfg.URL)
+	}
+	if cfg.Model == "" {
+		return nil, errors.New("embedding.model is required by the openai provider")
+	}
+
+	batchSize := cfg.BatchSize
+	if batchSize <= 0 {
+		batchSize = 128
+	}
+
+	return &openAIEmbedder{
+		client:     &http.Client{},
+		endpoint:   strings.TrimSuffix(cfg.URL, "/") + "/embeddings",
+		apiKey:     cfg.APIKey,
+		model:      cfg.Model,
+		dimensions: cfg.Dimension,
+		batchSize:  batchSize,
+		timeout:    cfg.Timeout,
+		retry:      retry,
+	}, nil
+}
+
+type openAIEmbeddingRequest struct {
+	Input      []string `json:"input"`
+	Model      string   `json:"model"`
+	Dimensions int      `json:"dimensions,omitempty"`
+}
+
+type openAIEmbeddingResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+}
+
+// Embed embeds the texts in requests of at most batchSize texts, sent in
+// order. Each request is retried on its own.
+func (e *openAIEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	embeddings := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += e.batchSize {
+		batch := texts[start:min(start+e.batchSize, len(texts))]
+
+		var batchEmbeddings [][]float32
+		err := withRetries(ctx, e.retry, nil, func(ctx context.Context) error {
+			var err error
+			batchEmbeddings, err = e.request(ctx, batch)
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to embed texts %d to %d: %w", start, start+len(batch)-1, err)
+		}
+		embeddings = append(embeddings, batchEmbeddings...)
+	}
+	return embeddings, nil
+}
+
+func (e *openAIEmbedder) request(ctx context.Context, texts []string) ([][]float32, error) {
+	if e.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.timeout)
+		defer cancel()
+	}
+
+	body, err := json.Marshal(openAIEmbeddingRequest{
+		Input:      texts,
+		Model:      e.model,
+		Dimensions: e.dimensions,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal embedding request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create embedding request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if e.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+e.apiKey)
+	}
+
+	resp, err := doHTTP(e.client, req, "embedding")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var res openAIEmbeddingResponse
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return nil, fmt.Errorf("failed to decode embedding response: %w", err)
+	}
+	if len(res.Data) != len(texts) {
+		return nil, fmt.Errorf("got %d embeddings for %d texts", len(res.Data), len(texts))
+	}
+
+	// the embeddings are ordered by their index, as the API doesn't
+	// guarantee the order of the data
+	embeddings := make([][]float32, len(texts))
+	for _, data := range res.Data {
+		if data.Index < 0 || data.Index >= len(texts) || embeddings[data.Index] != nil {
+			return nil, fmt.Errorf("invalid or duplicate embedding index %d", data.Index)
+		}
+		embeddings[data.Index] = data.Embedding
+	}
+	return embeddings, nil
+}
@@ -0,0 +1,198 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pinecone
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+// fakeEmbeddingServer is a local stand-in of an OpenAI compatible embeddings
+// API. The embedding of a text is its length followed by its position in the
+// request, so that tests can check which text got which embedding.
+type fakeEmbeddingServer struct {
+	*httptest.Server
+
+	m sync.Mutex
+	// requests are the decoded requests, including the failed ones.
+	requests []openAIEmbeddingRequest
+	// failures are the status codes returned by the next requests, instead of
+	// handling them. A zero status code delays the response by delay.
+	failures []int
+	delay    time.Duration
+	// authorization is the authorization header of the last request.
+	authorization string
+}
+
+func newFakeEmbeddingServer(t *testing.T) *fakeEmbeddingServer {
+	s := &fakeEmbeddingServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *fakeEmbeddingServer) handle(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/v1/embeddings" || r.Method != http.MethodPost {
+		http.NotFound(w, r)
+		return
+	}
+
+	var req openAIEmbeddingRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.m.Lock()
+	s.requests = append(s.requests, req)
+	s.authorization = r.Header.Get("Authorization")
+	var failure *int
+	if len(s.failures) > 0 {
+		failure = &s.failures[0]
+		s.failures = s.failures[1:]
+	}
+	delay := s.delay
+	s.m.Unlock()
+
+	switch {
+	case failure != nil && *failure == 0:
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+	case failure != nil:
+		http.Error(w, "fake failure", *failure)
+		return
+	}
+
+	// the data is sent in reverse order, which the client must handle
+	type data struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	}
+	res := struct {
+		Data []data `json:"data"`
+	}{}
+	for i := len(req.Input) - 1; i >= 0; i-- {
+		res.Data = append(res.Data, data{Index: i, Embedding: []float32{float32(len(req.Input[i])), float32(i)}})
+	}
+	_ = json.NewEncoder(w).Encode(res)
+}
+
+func TestOpenAIEmbedder(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	server := newFakeEmbeddingServer(t)
+
+	embedder, err := newOpenAIEmbedder(EmbeddingConfig{
+		URL:       server.URL + "/v1/",
+		APIKey:    "secret",
+		Model:     "test-model",
+		Dimension: 2,
+		BatchSize: 2,
+	}, RetryConfig{})
+	is.NoErr(err)
+
+	embeddings, err := embedder.Embed(ctx, []string{"a", "bb", "ccc", "dddd", "eeeee"})
+	is.NoErr(err)
+	is.Equal(embeddings, [][]float32{{1, 0}, {2, 1}, {3, 0}, {4, 1}, {5, 0}})
+
+	// the texts are embedded in batches of batchSize
+	is.Equal(len(server.requests), 3)
+	is.Equal(server.requests[0], openAIEmbeddingRequest{Input: []string{"a", "bb"}, Model: "test-model", Dimensions: 2})
+	is.Equal(server.requests[2].Input, []string{"eeeee"})
+	is.Equal(server.authorization, "Bearer secret")
+}
+
+func TestOpenAIEmbedder_Retries(t *testing.T) {
+	retry := RetryConfig{MaxRetries: 2, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+
+	testCases := []struct {
+		name         string
+		failures     []int
+		wantErr      bool
+		wantRequests int
+	}{
+		{name: "rate limited", failures: []int{http.StatusTooManyRequests}, wantRequests: 2},
+		{name: "unavailable", failures: []int{http.StatusServiceUnavailable, http.StatusBadGateway}, wantRequests: 3},
+		{name: "timeout", failures: []int{0}, wantRequests: 2},
+		{
+			name:         "retries exhausted",
+			failures:     []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusServiceUnavailable},
+			wantErr:      true,
+			wantRequests: 3,
+		},
+		{name: "bad request", failures: []int{http.StatusBadRequest}, wantErr: true, wantRequests: 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			is := is.New(t)
+			server := newFakeEmbeddingServer(t)
+			server.failures = tc.failures
+			server.delay = time.Second
+
+			embedder, err := newOpenAIEmbedder(EmbeddingConfig{
+				URL:       server.URL + "/v1",
+				Model:     "test-model",
+				BatchSize: 10,
+				Timeout:   50 * time.Millisecond,
+			}, retry)
+			is.NoErr(err)
+
+			embeddings, err := embedder.Embed(context.Background(), []string{"a", "bb"})
+			is.Equal(len(server.requests), tc.wantRequests)
+			if tc.wantErr {
+				is.True(err != nil)
+				// the texts are named instead of the vectors, which have no ID yet
+				is.True(strings.HasPrefix(err.Error(), "failed to embed texts 0 to 1: "))
+				is.True(!strings.Contains(err.Error(), "vectors"))
+				return
+			}
+			is.NoErr(err)
+			is.Equal(embeddings, [][]float32{{1, 0}, {2, 1}})
+			is.Equal(server.authorization, "") // no API key
+		})
+	}
+}
+
+func TestNewOpenAIEmbedder_Errors(t *testing.T) {
+	testCases := []struct {
+		name string
+		cfg  EmbeddingConfig
+	}{
+		{name: "missing url", cfg: EmbeddingConfig{Model: "test-model"}},
+		{name: "invalid scheme", cfg: EmbeddingConfig{URL: "ftp://localhost/v1", Model: "test-model"}},
+		{name: "missing model", cfg: EmbeddingConfig{URL: "http://localhost/v1"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			is := is.New(t)
+
+			_, err := newOpenAIEmbedder(tc.cfg, RetryConfig{})
+			is.True(err != nil)
+		})
+	}
+}
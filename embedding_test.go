@@ -0,0 +1,301 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pinecone
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/conduitio/conduit-commons/opencdc"
+	"github.com/google/uuid"
+	"github.com/matryer/is"
+)
+
+func TestHashEmbedder(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+
+	embedder := &hashEmbedder{dimension: 64}
+	embeddings, err := embedder.Embed(ctx, []string{
+		"The quick brown fox",
+		"the QUICK brown fox!",
+		"a lazy dog sleeps",
+	})
+	is.NoErr(err)
+	is.Equal(len(embeddings), 3)
+
+	for _, values := range embeddings {
+		is.Equal(len(values), 64)
+
+		var norm float64
+		for _, v := range values {
+			norm += float64(v) * float64(v)
+		}
+		is.True(math.Abs(norm-1) < 1e-5) // embeddings are normalized
+	}
+
+	// case and punctuation don't change the words
+	is.Equal(embeddings[0], embeddings[1])
+	is.True(cosine(embeddings[0], embeddings[1]) > cosine(embeddings[0], embeddings[2]))
+
+	// the same text always gets the same embedding
+	again, err := embedder.Embed(ctx, []string{"The quick brown fox"})
+	is.NoErr(err)
+	is.Equal(again[0], embeddings[0])
+
+	_, err = embedder.Embed(ctx, []string{"text", " ... "})
+	is.True(err != nil) // text without words
+}
+
+func cosine(a, b []float32) float64 {
+	var dot float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+	}
+	return dot
+}
+
+func TestNewEmbedder(t *testing.T) {
+	dense := &indexDescription{dimension: 8, vectorType: vectorTypeDense}
+	sparse := &indexDescription{vectorType: vectorTypeSparse}
+
+	testCases := []struct {
+		name          string
+		cfg           EmbeddingConfig
+		index         *indexDescription
+		wantDimension int
+		wantNil       bool
+		wantErr       bool
+	}{
+		{name: "disabled", cfg: EmbeddingConfig{Provider: embeddingProviderNone}, index: dense, wantNil: true},
+		{name: "index dimension", cfg: EmbeddingConfig{Provider: embeddingProviderLocal}, index: dense, wantDimension: 8},
+		{
+			name:          "configured dimension",
+			cfg:           EmbeddingConfig{Provider: embeddingProviderLocal, Dimension: 4},
+			wantDimension: 4,
+		},
+		{name: "unknown dimension", cfg: EmbeddingConfig{Provider: embeddingProviderLocal}, wantErr: true},
+		{name: "sparse index", cfg: EmbeddingConfig{Provider: embeddingProviderLocal}, index: sparse, wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			is := is.New(t)
+
+			embedder, err := newEmbedder(tc.cfg, RetryConfig{}, tc.index)
+			if tc.wantErr {
+				is.True(err != nil)
+				return
+			}
+			is.NoErr(err)
+			if tc.wantNil {
+				is.Equal(embedder, nil)
+				return
+			}
+			is.Equal(embedder.(*hashEmbedder).dimension, tc.wantDimension)
+		})
+	}
+}
+
+func TestUpsertBatch_Embed(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+
+	opts, err := DestinationConfig{
+		Fields:    defaultFieldsConfig,
+		Embedding: EmbeddingConfig{Provider: embeddingProviderLocal, Field: "doc.text"},
+	}.writerOptions(&indexDescription{dimension: 2, vectorType: vectorTypeDense})
+	is.NoErr(err)
+
+	colWriter := singleCollectionWriter{opts: opts}
+	batches, err := colWriter.buildBatches(ctx, []opencdc.Record{
+		{
+			Operation: opencdc.OperationCreate,
+			Key:       opencdc.RawData("key1"),
+			Payload:   opencdc.Change{After: opencdc.RawData(`{"doc": {"text": "some text"}}`)},
+		},
+		{
+			Operation: opencdc.OperationCreate,
+			Key:       opencdc.RawData("key2"),
+			Payload:   opencdc.Change{After: opencdc.RawData(`{"values": [1, 2], "doc": {"text": "ignored"}}`)},
+		},
+	})
+	is.NoErr(err)
+	is.Equal(len(batches), 1)
+
+	batch := batches[0].(*upsertBatch)
+	is.Equal(batch.embeddings, []pendingEmbedding{{vector: 0, text: "some text"}})
+
+	valid, err := batch.embed(ctx)
+	is.NoErr(err)
+	is.Equal(valid, 2)
+	is.Equal(len(batch.vectors[0].Values), 2)
+	is.Equal(batch.vectors[1].Values, []float32{1, 2})
+	is.Equal(len(batch.embeddings), 0)
+
+	t.Run("invalid embedding", func(t *testing.T) {
+		is := is.New(t)
+
+		destCfg := destConfigFromEnv(t)
+		destCfg.Namespace = fmt.Sprintf("test-embed%s", uuid.NewString()[:8])
+		index := createIndex(is, destCfg)
+		defer deleteAllRecords(is, index)
+
+		// the embedding of the second text doesn't match the index dimension
+		opts := opts
+		opts.embedder = embedderFunc(func(text string) []float32 {
+			if text == "wrong dimension" {
+				return []float32{1, 2, 3}
+			}
+			return []float32{1, 2}
+		})
+
+		colWriter := singleCollectionWriter{opts: opts}
+		batches, err := colWriter.buildBatches(ctx, []opencdc.Record{
+			{
+				Operation: opencdc.OperationCreate,
+				Key:       opencdc.RawData("key1"),
+				Payload:   opencdc.Change{After: opencdc.RawData(`{"doc": {"text": "some text"}}`)},
+			},
+			{
+				Operation: opencdc.OperationCreate,
+				Key:       opencdc.RawData("key2"),
+				Payload:   opencdc.Change{After: opencdc.RawData(`{"doc": {"text": "wrong dimension"}}`)},
+			},
+			{
+				Operation: opencdc.OperationCreate,
+				Key:       opencdc.RawData("key3"),
+				Payload:   opencdc.Change{After: opencdc.RawData(`{"doc": {"text": "other text"}}`)},
+			},
+		})
+		is.NoErr(err)
+		is.Equal(len(batches), 1)
+
+		// the record before the invalid one is still written
+		written, err := batches[0].writeBatch(ctx, index)
+		is.True(err != nil)
+		is.True(strings.Contains(err.Error(), "invalid embedding"))
+		is.Equal(written, 1)
+
+		res, err := index.FetchVectors(ctx, []string{"key1", "key2", "key3"})
+		is.NoErr(err)
+		is.Equal(len(res.Vectors), 1)
+		is.True(res.Vectors["key1"] != nil)
+	})
+
+	t.Run("missing text", func(t *testing.T) {
+		is := is.New(t)
+
+		_, err := colWriter.buildBatches(ctx, []opencdc.Record{{
+			Operation: opencdc.OperationCreate,
+			Key:       opencdc.RawData("key1"),
+			Payload:   opencdc.Change{After: opencdc.RawData(`{"doc": {}}`)},
+		}})
+		is.True(err != nil)
+	})
+
+	t.Run("text without words", func(t *testing.T) {
+		is := is.New(t)
+
+		// the record fails on its own, the batch before it can be written
+		batches, err := colWriter.buildBatches(ctx, []opencdc.Record{
+			{
+				Operation: opencdc.OperationCreate,
+				Key:       opencdc.RawData("key1"),
+				Payload:   opencdc.Change{After: opencdc.RawData(`{"doc": {"text": "some text"}}`)},
+			},
+			{
+				Operation: opencdc.OperationCreate,
+				Key:       opencdc.RawData("key2"),
+				Payload:   opencdc.Change{After: opencdc.RawData(`{"doc": {"text": "..."}}`)},
+			},
+		})
+		is.True(err != nil)
+		is.True(strings.Contains(err.Error(), `vector "key2"`))
+		is.Equal(len(batches), 1)
+		is.Equal(len(batches[0].(*upsertBatch).vectors), 1)
+	})
+}
+
+// embedderFunc embeds each text with the function.
+type embedderFunc func(text string) []float32
+
+func (f embedderFunc) Embed(_ context.Context, texts []string) ([][]float32, error) {
+	embeddings := make([][]float32, len(texts))
+	for i, text := range texts {
+		embeddings[i] = f(text)
+	}
+	return embeddings, nil
+}
+
+func TestEmbeddingText(t *testing.T) {
+	rec := opencdc.Record{
+		Key:     opencdc.RawData("key1"),
+		Payload: opencdc.Change{After: opencdc.RawData(`{"title": "A title", "body": "Some body"}`)},
+	}
+	payload := map[string]any{"title": "A title", "body": "Some body"}
+
+	testCases := []struct {
+		name    string
+		cfg     EmbeddingConfig
+		obj     any
+		want    string
+		wantErr bool
+	}{
+		{name: "field", cfg: EmbeddingConfig{Field: "doc.text"}, obj: map[string]any{"doc": map[string]any{"text": "hello"}}, want: "hello"},
+		{
+			name: "template",
+			cfg:  EmbeddingConfig{Template: `{{ .Payload.After.title }}: {{ .Payload.After.body }}`},
+			obj:  payload,
+			want: "A title: Some body",
+		},
+		{name: "missing field", cfg: EmbeddingConfig{Field: "text"}, obj: map[string]any{}, wantErr: true},
+		{name: "field not a string", cfg: EmbeddingConfig{Field: "text"}, obj: map[string]any{"text": 1.0}, wantErr: true},
+		{name: "empty text", cfg: EmbeddingConfig{Field: "text"}, obj: map[string]any{"text": "  "}, wantErr: true},
+		{name: "text without words", cfg: EmbeddingConfig{Field: "text"}, obj: map[string]any{"text": "..."}, wantErr: true},
+		{name: "template missing key", cfg: EmbeddingConfig{Template: `{{ .Payload.After.missing }}`}, obj: payload, wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			is := is.New(t)
+
+			tc.cfg.Provider = embeddingProviderLocal
+			text, err := newEmbeddingText(tc.cfg, FieldsConfig{})
+			is.NoErr(err)
+
+			got, err := text.text(rec, tc.obj)
+			if tc.wantErr {
+				is.True(err != nil)
+				return
+			}
+			is.NoErr(err)
+			is.Equal(got, tc.want)
+		})
+	}
+
+	t.Run("template with chunks", func(t *testing.T) {
+		is := is.New(t)
+
+		_, err := newEmbeddingText(
+			EmbeddingConfig{Provider: embeddingProviderLocal, Template: `{{ .Key }}`},
+			FieldsConfig{Chunks: "chunks"},
+		)
+		is.True(err != nil)
+	})
+}
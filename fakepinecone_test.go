@@ -0,0 +1,488 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pinecone
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// fakePinecone is an in-memory implementation of the Pinecone data plane gRPC
+// service, so that tests can run without a real Pinecone index.
+//
+// The generated gRPC code of the Pinecone client is internal, so the server
+// handles the calls as unknown services instead. Requests and responses are
+// converted from and to protobuf messages through their JSON representation,
+// using the message types that the client registers in the global registry.
+type fakePinecone struct {
+	server   *grpc.Server
+	listener net.Listener
+
+	dimension uint32
+
+	m sync.Mutex
+	// namespaces maps each namespace to its vectors, by ID.
+	namespaces map[string]map[string]*fakeVector
+	// failures are the errors returned by the next calls of each method,
+	// instead of handling them.
+	failures map[string][]error
+	// calls counts the calls of each method, including the failed ones.
+	calls map[string]int
+}
+
+type fakeVector struct {
+	ID           string            `json:"id"`
+	Values       []float32         `json:"values,omitempty"`
+	SparseValues *fakeSparseValues `json:"sparseValues,omitempty"`
+	Metadata     map[string]any    `json:"metadata,omitempty"`
+}
+
+type fakeSparseValues struct {
+	Indices []uint32  `json:"indices,omitempty"`
+	Values  []float32 `json:"values,omitempty"`
+}
+
+const fakeListDefaultLimit = 100
+
+// newFakePinecone starts a fake Pinecone server listening on a random local
+// port. Upserted dense vectors must have the given dimension.
+func newFakePinecone(dimension uint32) (*fakePinecone, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen: %w", err)
+	}
+
+	f := &fakePinecone{
+		listener:   listener,
+		dimension:  dimension,
+		namespaces: make(map[string]map[string]*fakeVector),
+		failures:   make(map[string][]error),
+		calls:      make(map[string]int),
+	}
+	f.server = grpc.NewServer(grpc.UnknownServiceHandler(f.handle))
+
+	go func() {
+		_ = f.server.Serve(listener)
+	}()
+
+	return f, nil
+}
+
+// host returns the plaintext URL of the server.
+func (f *fakePinecone) host() string {
+	return "http://" + f.listener.Addr().String()
+}
+
+func (f *fakePinecone) stop() {
+	f.server.Stop()
+}
+
+// controlPlane starts a fake Pinecone control plane describing the index of
+// the server under the given name and metric, and returns its URL.
+func (f *fakePinecone) controlPlane(t *testing.T, name, metric string) string {
+	vectorType := vectorTypeDense
+	if f.dimension == 0 {
+		vectorType = vectorTypeSparse
+	}
+	desc := map[string]any{
+		"name":        name,
+		"host":        f.listener.Addr().String(),
+		"metric":      metric,
+		"vector_type": vectorType,
+	}
+	if f.dimension > 0 {
+		desc["dimension"] = f.dimension
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/indexes/"+name || r.Header.Get("Api-Key") == "" {
+			http.NotFound(w, r)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(desc)
+	}))
+	t.Cleanup(server.Close)
+
+	return server.URL
+}
+
+// failNext makes the next calls of the method, e.g. "Upsert", return the
+// given errors, one per call. A nil error lets its call through.
+func (f *fakePinecone) failNext(method string, errs ...error) {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	f.failures[method] = append(f.failures[method], errs...)
+}
+
+// callCount returns the number of calls of the method since the last reset.
+func (f *fakePinecone) callCount(method string) int {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	return f.calls[method]
+}
+
+// resetCalls clears the call counts and the pending failures.
+func (f *fakePinecone) resetCalls() {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	f.failures = make(map[string][]error)
+	f.calls = make(map[string]int)
+}
+
+func (f *fakePinecone) handle(_ any, stream grpc.ServerStream) error {
+	fullMethod, ok := grpc.MethodFromServerStream(stream)
+	if !ok {
+		return status.Error(codes.Internal, "method not found in stream")
+	}
+
+	if md, _ := metadata.FromIncomingContext(stream.Context()); len(md.Get("api-key")) == 0 {
+		return status.Error(codes.Unauthenticated, "missing api key")
+	}
+
+	method, ok := strings.CutPrefix(fullMethod, "/VectorService/")
+	if !ok {
+		return status.Errorf(codes.Unimplemented, "unknown method %s", fullMethod)
+	}
+
+	reqType, err := protoregistry.GlobalTypes.FindMessageByName(protoreflect.FullName(method + "Request"))
+	if err != nil {
+		return status.Errorf(codes.Unimplemented, "unknown method %s", fullMethod)
+	}
+	resType, err := protoregistry.GlobalTypes.FindMessageByName(protoreflect.FullName(method + "Response"))
+	if err != nil {
+		return status.Errorf(codes.Unimplemented, "unknown method %s", fullMethod)
+	}
+
+	req := reqType.New().Interface()
+	if err := stream.RecvMsg(req); err != nil {
+		return err
+	}
+
+	reqJSON, err := protojson.Marshal(req)
+	if err != nil {
+		return status.Errorf(codes.Internal, "failed to marshal request: %v", err)
+	}
+
+	res, err := f.call(method, reqJSON)
+	if err != nil {
+		return err
+	}
+
+	resJSON, err := json.Marshal(res)
+	if err != nil {
+		return status.Errorf(codes.Internal, "failed to marshal response: %v", err)
+	}
+
+	out := resType.New().Interface()
+	if err := protojson.Unmarshal(resJSON, out); err != nil {
+		return status.Errorf(codes.Internal, "failed to unmarshal response: %v", err)
+	}
+
+	return stream.SendMsg(out)
+}
+
+func (f *fakePinecone) call(method string, reqJSON []byte) (any, error) {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	f.calls[method]++
+	if errs := f.failures[method]; len(errs) > 0 {
+		f.failures[method] = errs[1:]
+		// a nil error lets the call through
+		if errs[0] != nil {
+			return nil, errs[0]
+		}
+	}
+
+	switch method {
+	case "Upsert":
+		return callWith(reqJSON, f.upsert)
+	case "Delete":
+		return callWith(reqJSON, f.delete)
+	case "Fetch":
+		return callWith(reqJSON, f.fetch)
+	case "List":
+		return callWith(reqJSON, f.list)
+	case "Update":
+		return callWith(reqJSON, f.update)
+	case "DescribeIndexStats":
+		return callWith(reqJSON, f.describeIndexStats)
+	default:
+		return nil, status.Errorf(codes.Unimplemented, "method %s not implemented", method)
+	}
+}
+
+func callWith[Req any](reqJSON []byte, fn func(Req) (any, error)) (any, error) {
+	var req Req
+	if err := json.Unmarshal(reqJSON, &req); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "failed to parse request: %v", err)
+	}
+	return fn(req)
+}
+
+func (f *fakePinecone) namespace(name string) map[string]*fakeVector {
+	vectors, ok := f.namespaces[name]
+	if !ok {
+		vectors = make(map[string]*fakeVector)
+		f.namespaces[name] = vectors
+	}
+	return vectors
+}
+
+type fakeUpsertRequest struct {
+	Vectors   []*fakeVector `json:"vectors"`
+	Namespace string        `json:"namespace"`
+}
+
+func (f *fakePinecone) upsert(req fakeUpsertRequest) (any, error) {
+	for _, vec := range req.Vectors {
+		if err := f.validateVector(vec); err != nil {
+			return nil, err
+		}
+	}
+
+	vectors := f.namespace(req.Namespace)
+	for _, vec := range req.Vectors {
+		vectors[vec.ID] = vec
+	}
+
+	return map[string]any{"upsertedCount": len(req.Vectors)}, nil
+}
+
+func (f *fakePinecone) validateVector(vec *fakeVector) error {
+	if vec.ID == "" {
+		return status.Error(codes.InvalidArgument, "vector id must not be empty")
+	}
+	if len(vec.Values) == 0 && vec.SparseValues == nil {
+		return status.Errorf(codes.InvalidArgument, "vector %s has no values", vec.ID)
+	}
+	if f.dimension != 0 && len(vec.Values) != int(f.dimension) {
+		return status.Errorf(codes.InvalidArgument,
+			"vector %s dimension %d does not match the dimension of the index %d",
+			vec.ID, len(vec.Values), f.dimension)
+	}
+	if sv := vec.SparseValues; sv != nil && len(sv.Indices) != len(sv.Values) {
+		return status.Errorf(codes.InvalidArgument,
+			"vector %s sparse indices and values have different lengths", vec.ID)
+	}
+	return nil
+}
+
+type fakeDeleteRequest struct {
+	IDs       []string       `json:"ids"`
+	DeleteAll bool           `json:"deleteAll"`
+	Namespace string         `json:"namespace"`
+	Filter    map[string]any `json:"filter"`
+}
+
+func (f *fakePinecone) delete(req fakeDeleteRequest) (any, error) {
+	switch {
+	case req.DeleteAll:
+		delete(f.namespaces, req.Namespace)
+	case req.Filter != nil:
+		vectors := f.namespace(req.Namespace)
+		for id, vec := range vectors {
+			matches, err := fakeMatchesFilter(vec.Metadata, req.Filter)
+			if err != nil {
+				return nil, err
+			}
+			if matches {
+				delete(vectors, id)
+			}
+		}
+	default:
+		vectors := f.namespace(req.Namespace)
+		for _, id := range req.IDs {
+			delete(vectors, id)
+		}
+	}
+
+	return map[string]any{}, nil
+}
+
+// fakeMatchesFilter supports the subset of the Pinecone metadata filters used
+// by the tests: field equality, "$eq", "$in" and "$and".
+func fakeMatchesFilter(metadata, filter map[string]any) (bool, error) {
+	for key, cond := range filter {
+		if key == "$and" {
+			filters, _ := cond.([]any)
+			for _, sub := range filters {
+				subFilter, _ := sub.(map[string]any)
+				matches, err := fakeMatchesFilter(metadata, subFilter)
+				if err != nil || !matches {
+					return false, err
+				}
+			}
+			continue
+		}
+
+		value := metadata[key]
+		ops, ok := cond.(map[string]any)
+		if !ok {
+			ops = map[string]any{"$eq": cond}
+		}
+		for op, operand := range ops {
+			switch op {
+			case "$eq":
+				if value != operand {
+					return false, nil
+				}
+			case "$in":
+				items, _ := operand.([]any)
+				if !slices.Contains(items, value) {
+					return false, nil
+				}
+			default:
+				return false, status.Errorf(codes.Unimplemented, "filter operator %s not implemented", op)
+			}
+		}
+	}
+	return true, nil
+}
+
+type fakeFetchRequest struct {
+	IDs       []string `json:"ids"`
+	Namespace string   `json:"namespace"`
+}
+
+func (f *fakePinecone) fetch(req fakeFetchRequest) (any, error) {
+	vectors := make(map[string]*fakeVector)
+	for _, id := range req.IDs {
+		if vec, ok := f.namespaces[req.Namespace][id]; ok {
+			vectors[id] = vec
+		}
+	}
+
+	return map[string]any{
+		"vectors":   vectors,
+		"namespace": req.Namespace,
+		"usage":     map[string]any{"readUnits": 1},
+	}, nil
+}
+
+type fakeListRequest struct {
+	Prefix          string `json:"prefix"`
+	Limit           int    `json:"limit"`
+	PaginationToken string `json:"paginationToken"`
+	Namespace       string `json:"namespace"`
+}
+
+// list returns the vector IDs sorted, the pagination token being the last
+// returned ID.
+func (f *fakePinecone) list(req fakeListRequest) (any, error) {
+	limit := req.Limit
+	if limit == 0 {
+		limit = fakeListDefaultLimit
+	}
+
+	var items []map[string]any
+	var next string
+	for _, id := range slices.Sorted(maps.Keys(f.namespaces[req.Namespace])) {
+		if !strings.HasPrefix(id, req.Prefix) || id <= req.PaginationToken {
+			continue
+		}
+		if len(items) == limit {
+			next = items[len(items)-1]["id"].(string)
+			break
+		}
+		items = append(items, map[string]any{"id": id})
+	}
+
+	res := map[string]any{
+		"vectors":   items,
+		"namespace": req.Namespace,
+		"usage":     map[string]any{"readUnits": 1},
+	}
+	if next != "" {
+		res["pagination"] = map[string]any{"next": next}
+	}
+
+	return res, nil
+}
+
+type fakeUpdateRequest struct {
+	ID           string            `json:"id"`
+	Values       []float32         `json:"values"`
+	SparseValues *fakeSparseValues `json:"sparseValues"`
+	SetMetadata  map[string]any    `json:"setMetadata"`
+	Namespace    string            `json:"namespace"`
+}
+
+func (f *fakePinecone) update(req fakeUpdateRequest) (any, error) {
+	vec, ok := f.namespaces[req.Namespace][req.ID]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "vector %s not found", req.ID)
+	}
+
+	updated := *vec
+	if req.Values != nil {
+		updated.Values = req.Values
+	}
+	if req.SparseValues != nil {
+		updated.SparseValues = req.SparseValues
+	}
+	if req.SetMetadata != nil {
+		updated.Metadata = make(map[string]any)
+		maps.Copy(updated.Metadata, vec.Metadata)
+		maps.Copy(updated.Metadata, req.SetMetadata)
+	}
+
+	if err := f.validateVector(&updated); err != nil {
+		return nil, err
+	}
+	f.namespaces[req.Namespace][req.ID] = &updated
+
+	return map[string]any{}, nil
+}
+
+type fakeDescribeIndexStatsRequest struct{}
+
+func (f *fakePinecone) describeIndexStats(fakeDescribeIndexStatsRequest) (any, error) {
+	namespaces := make(map[string]any)
+	var total int
+	for name, vectors := range f.namespaces {
+		// like Pinecone, empty namespaces are not reported
+		if len(vectors) == 0 {
+			continue
+		}
+		namespaces[name] = map[string]any{"vectorCount": len(vectors)}
+		total += len(vectors)
+	}
+
+	return map[string]any{
+		"namespaces":       namespaces,
+		"dimension":        f.dimension,
+		"totalVectorCount": total,
+	}, nil
+}
@@ -0,0 +1,90 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pinecone
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// maxErrorBodyBytes is the maximum number of bytes of the body of a failed
+// response added to the error.
+const maxErrorBodyBytes = 1024
+
+// doHTTP sends the request of the API named by name, like "embedding". The
+// response is only returned if it has a 2xx status, the caller closing its
+// body. Errors tell whether the request can be retried.
+func doHTTP(client *http.Client, req *http.Request, name string) (*http.Response, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		// timeouts and connection errors are transient, unless the write
+		// itself is canceled
+		transient := !errors.Is(req.Context().Err(), context.Canceled)
+		return nil, &httpRequestError{name: name, err: err, transient: transient}
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodyBytes))
+		return nil, &httpStatusError{name: name, statusCode: resp.StatusCode, body: strings.TrimSpace(string(msg))}
+	}
+	return resp, nil
+}
+
+// httpRequestError is an HTTP request that got no response.
+type httpRequestError struct {
+	name      string
+	err       error
+	transient bool
+}
+
+func (e *httpRequestError) Error() string {
+	return fmt.Sprintf("%s request failed: %v", e.name, e.err)
+}
+
+func (e *httpRequestError) Unwrap() error {
+	return e.err
+}
+
+func (e *httpRequestError) retryable() bool {
+	return e.transient
+}
+
+// httpStatusError is an HTTP request that got an error response.
+type httpStatusError struct {
+	name       string
+	statusCode int
+	body       string
+}
+
+func (e *httpStatusError) Error() string {
+	return fmt.Sprintf("%s request failed with status %d: %s", e.name, e.statusCode, e.body)
+}
+
+// retryable returns whether the request was rate limited or the server failed
+// transiently.
+func (e *httpStatusError) retryable() bool {
+	switch e.statusCode {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
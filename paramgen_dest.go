@@ -8,9 +8,69 @@ import (
 )
 
 const (
-	DestinationConfigApiKey    = "apiKey"
-	DestinationConfigHost      = "host"
-	DestinationConfigNamespace = "namespace"
+	DestinationConfigApiKey                              = "apiKey"
+	DestinationConfigControlPlaneHost                    = "controlPlaneHost"
+	DestinationConfigDeleteFilter                        = "deleteFilter"
+	DestinationConfigDeleteMode                          = "deleteMode"
+	DestinationConfigDeletePrefixSeparator               = "deletePrefixSeparator"
+	DestinationConfigEmbeddingApiKey                     = "embedding.apiKey"
+	DestinationConfigEmbeddingBatchSize                  = "embedding.batchSize"
+	DestinationConfigEmbeddingDimension                  = "embedding.dimension"
+	DestinationConfigEmbeddingField                      = "embedding.field"
+	DestinationConfigEmbeddingModel                      = "embedding.model"
+	DestinationConfigEmbeddingProvider                   = "embedding.provider"
+	DestinationConfigEmbeddingTemplate                   = "embedding.template"
+	DestinationConfigEmbeddingTimeout                    = "embedding.timeout"
+	DestinationConfigEmbeddingUrl                        = "embedding.url"
+	DestinationConfigFieldsChunkID                       = "fields.chunkID"
+	DestinationConfigFieldsChunkSeparator                = "fields.chunkSeparator"
+	DestinationConfigFieldsChunks                        = "fields.chunks"
+	DestinationConfigFieldsMetadata                      = "fields.metadata"
+	DestinationConfigFieldsRequired                      = "fields.required"
+	DestinationConfigFieldsSparseIndices                 = "fields.sparseIndices"
+	DestinationConfigFieldsSparseValues                  = "fields.sparseValues"
+	DestinationConfigFieldsValues                        = "fields.values"
+	DestinationConfigHost                                = "host"
+	DestinationConfigId                                  = "id"
+	DestinationConfigIndexName                           = "indexName"
+	DestinationConfigInvalidIDOriginalKeyField           = "invalidID.originalKeyField"
+	DestinationConfigInvalidIDPolicy                     = "invalidID.policy"
+	DestinationConfigInvalidIDUuidNamespace              = "invalidID.uuidNamespace"
+	DestinationConfigMaxRequestBytes                     = "maxRequestBytes"
+	DestinationConfigMaxRequestVectors                   = "maxRequestVectors"
+	DestinationConfigMetadataCoerce                      = "metadata.coerce"
+	DestinationConfigMetadataCoerceMode                  = "metadata.coerceMode"
+	DestinationConfigMetadataDropInternal                = "metadata.dropInternal"
+	DestinationConfigMetadataDropKeys                    = "metadata.dropKeys"
+	DestinationConfigMetadataExclude                     = "metadata.exclude"
+	DestinationConfigMetadataInclude                     = "metadata.include"
+	DestinationConfigMetadataListDelimiter               = "metadata.listDelimiter"
+	DestinationConfigMetadataMaxSize                     = "metadata.maxSize"
+	DestinationConfigMetadataOverflowPolicy              = "metadata.overflowPolicy"
+	DestinationConfigMetadataPayloadFields               = "metadata.payloadFields"
+	DestinationConfigMetadataRename                      = "metadata.rename"
+	DestinationConfigNamespace                           = "namespace"
+	DestinationConfigRateLimitNamespaceRequestsPerSecond = "rateLimit.namespaceRequestsPerSecond"
+	DestinationConfigRateLimitNamespaceVectorsPerSecond  = "rateLimit.namespaceVectorsPerSecond"
+	DestinationConfigRateLimitRequestsPerSecond          = "rateLimit.requestsPerSecond"
+	DestinationConfigRateLimitVectorsPerSecond           = "rateLimit.vectorsPerSecond"
+	DestinationConfigRecordsBatchSize                    = "records.batchSize"
+	DestinationConfigRecordsField                        = "records.field"
+	DestinationConfigRecordsIndexField                   = "records.indexField"
+	DestinationConfigRecordsTimeout                      = "records.timeout"
+	DestinationConfigRetryInitialBackoff                 = "retry.initialBackoff"
+	DestinationConfigRetryMaxBackoff                     = "retry.maxBackoff"
+	DestinationConfigRetryMaxRetries                     = "retry.maxRetries"
+	DestinationConfigSparseAvgDocLength                  = "sparse.avgDocLength"
+	DestinationConfigSparseB                             = "sparse.b"
+	DestinationConfigSparseEncoder                       = "sparse.encoder"
+	DestinationConfigSparseField                         = "sparse.field"
+	DestinationConfigSparseK1                            = "sparse.k1"
+	DestinationConfigSparseStatsFile                     = "sparse.statsFile"
+	DestinationConfigTlsCaCertFile                       = "tls.caCertFile"
+	DestinationConfigTlsInsecureSkipVerify               = "tls.insecureSkipVerify"
+	DestinationConfigUpdateMode                          = "updateMode"
+	DestinationConfigUpsertMode                          = "upsertMode"
 )
 
 func (DestinationConfig) Parameters() map[string]config.Parameter {
@@ -23,6 +83,142 @@ func (DestinationConfig) Parameters() map[string]config.Parameter {
 				config.ValidationRequired{},
 			},
 		},
+		DestinationConfigControlPlaneHost: {
+			Default:     "https://api.pinecone.io",
+			Description: "ControlPlaneHost is the URL of the Pinecone control plane, which\ndescribes the index named by indexName.",
+			Type:        config.ParameterTypeString,
+			Validations: []config.Validation{},
+		},
+		DestinationConfigDeleteFilter: {
+			Default:     "",
+			Description: "DeleteFilter is a [Go template](https://pkg.go.dev/text/template)\nexecuted for each delete record to render a Pinecone metadata filter,\nas a JSON object. On top of the functions of the id template, the json\nfunction encodes values. Required by the filter delete mode.",
+			Type:        config.ParameterTypeString,
+			Validations: []config.Validation{},
+		},
+		DestinationConfigDeleteMode: {
+			Default:     "id",
+			Description: "DeleteMode is one of \"id\", deleting the vector of delete records by ID,\n\"filter\", deleting the vectors matching the metadata filter rendered by\ndeleteFilter, or \"prefix\", deleting all the vectors whose ID starts with\nthe vector ID of the record followed by deletePrefixSeparator.",
+			Type:        config.ParameterTypeString,
+			Validations: []config.Validation{
+				config.ValidationInclusion{List: []string{"id", "filter", "prefix"}},
+			},
+		},
+		DestinationConfigDeletePrefixSeparator: {
+			Default:     "#",
+			Description: "DeletePrefixSeparator separates the document ID from the chunk in the\nvector IDs deleted by the prefix delete mode, like \"doc123#chunk0\".",
+			Type:        config.ParameterTypeString,
+			Validations: []config.Validation{},
+		},
+		DestinationConfigEmbeddingApiKey: {
+			Default:     "",
+			Description: "APIKey is sent as a bearer token to the OpenAI compatible API, if set.",
+			Type:        config.ParameterTypeString,
+			Validations: []config.Validation{},
+		},
+		DestinationConfigEmbeddingBatchSize: {
+			Default:     "128",
+			Description: "BatchSize is the maximum number of texts embedded by a single request\nto the OpenAI compatible API. The texts of larger batches are embedded\nin multiple requests.",
+			Type:        config.ParameterTypeInt,
+			Validations: []config.Validation{
+				config.ValidationGreaterThan{V: 0},
+			},
+		},
+		DestinationConfigEmbeddingDimension: {
+			Default:     "",
+			Description: "Dimension is the dimension of the embeddings. The local embeddings\ndefault to the dimension of the index if zero, while the openai\nprovider only sends it to the API if set.",
+			Type:        config.ParameterTypeInt,
+			Validations: []config.Validation{
+				config.ValidationGreaterThan{V: -1},
+			},
+		},
+		DestinationConfigEmbeddingField: {
+			Default:     "text",
+			Description: "Field is the field holding the text to embed, as a dot separated path\nor a JSON pointer. It's read within each chunk if records are fanned\nout into chunks.",
+			Type:        config.ParameterTypeString,
+			Validations: []config.Validation{},
+		},
+		DestinationConfigEmbeddingModel: {
+			Default:     "",
+			Description: "Model is the embedding model of the OpenAI compatible API. Required by\nthe openai provider.",
+			Type:        config.ParameterTypeString,
+			Validations: []config.Validation{},
+		},
+		DestinationConfigEmbeddingProvider: {
+			Default:     "none",
+			Description: "Provider is one of \"none\", disabling embeddings, \"local\", embedding\ntexts with a deterministic feature hashing model that needs no GPU nor\nnetwork access, or \"openai\", calling an OpenAI compatible embeddings\nAPI.",
+			Type:        config.ParameterTypeString,
+			Validations: []config.Validation{
+				config.ValidationInclusion{List: []string{"none", "local", "openai"}},
+			},
+		},
+		DestinationConfigEmbeddingTemplate: {
+			Default:     "",
+			Description: "Template is a [Go template](https://pkg.go.dev/text/template) executed\nfor each record to render the text to embed, instead of reading field.\nIt can't be used with chunks.",
+			Type:        config.ParameterTypeString,
+			Validations: []config.Validation{},
+		},
+		DestinationConfigEmbeddingTimeout: {
+			Default:     "30s",
+			Description: "Timeout is the timeout of a single request to the OpenAI compatible\nAPI. Requests timing out are retried like failed writes.",
+			Type:        config.ParameterTypeDuration,
+			Validations: []config.Validation{},
+		},
+		DestinationConfigEmbeddingUrl: {
+			Default:     "",
+			Description: "URL is the base URL of the OpenAI compatible API, like\n\"https://api.openai.com/v1\", to which \"/embeddings\" is appended.\nRequired by the openai provider.",
+			Type:        config.ParameterTypeString,
+			Validations: []config.Validation{},
+		},
+		DestinationConfigFieldsChunkID: {
+			Default:     "",
+			Description: "ChunkID is the chunk field holding the vector ID of the chunk. If\nempty, the ID of a chunk is the vector ID of the record followed by\nchunkSeparator and the position of the chunk, like \"doc123#0\".",
+			Type:        config.ParameterTypeString,
+			Validations: []config.Validation{},
+		},
+		DestinationConfigFieldsChunkSeparator: {
+			Default:     "#",
+			Description: "ChunkSeparator separates the vector ID of the record from the position\nof the chunk in the generated chunk IDs.",
+			Type:        config.ParameterTypeString,
+			Validations: []config.Validation{},
+		},
+		DestinationConfigFieldsChunks: {
+			Default:     "",
+			Description: "Chunks is the field holding a list of chunks, fanning the record out\ninto one vector per chunk. Each chunk is an object holding the values,\nsparse values and metadata fields of its vector. Records are written as\na single vector if empty.",
+			Type:        config.ParameterTypeString,
+			Validations: []config.Validation{},
+		},
+		DestinationConfigFieldsMetadata: {
+			Default:     "",
+			Description: "Metadata is the field holding an object whose entries are added to the\nvector metadata, on top of the record metadata. No payload field is\nadded to the metadata if empty.",
+			Type:        config.ParameterTypeString,
+			Validations: []config.Validation{},
+		},
+		DestinationConfigFieldsRequired: {
+			Default:     "any",
+			Description: "Required is the vector components that records must have. It's one of\n\"any\", requiring dense or sparse values, \"dense\", \"sparse\" or \"both\".",
+			Type:        config.ParameterTypeString,
+			Validations: []config.Validation{
+				config.ValidationInclusion{List: []string{"any", "dense", "sparse", "both"}},
+			},
+		},
+		DestinationConfigFieldsSparseIndices: {
+			Default:     "sparse_values.indices",
+			Description: "SparseIndices is the field holding the sparse vector indices.",
+			Type:        config.ParameterTypeString,
+			Validations: []config.Validation{},
+		},
+		DestinationConfigFieldsSparseValues: {
+			Default:     "sparse_values.values",
+			Description: "SparseValues is the field holding the sparse vector values.",
+			Type:        config.ParameterTypeString,
+			Validations: []config.Validation{},
+		},
+		DestinationConfigFieldsValues: {
+			Default:     "values",
+			Description: "Values is the field holding the dense vector values.",
+			Type:        config.ParameterTypeString,
+			Validations: []config.Validation{},
+		},
 		DestinationConfigHost: {
 			Default:     "",
 			Description: "Host is the whole Pinecone index host URL.",
@@ -31,11 +227,275 @@ func (DestinationConfig) Parameters() map[string]config.Parameter {
 				config.ValidationRequired{},
 			},
 		},
+		DestinationConfigId: {
+			Default:     "",
+			Description: "ID is a [Go template](https://pkg.go.dev/text/template) executed for\neach record to build the vector ID, used by both upserts and deletes.\nThe join, prefix, sha256 and string functions help building composite\nIDs out of structured keys. Defaults to the record key.",
+			Type:        config.ParameterTypeString,
+			Validations: []config.Validation{},
+		},
+		DestinationConfigIndexName: {
+			Default:     "",
+			Description: "IndexName is the name of the index. If set, the index is described by\nthe control plane when the destination is opened, which tells its\nvector type and metric. Otherwise, its host only tells its dimension,\nand indexes without one, like sparse indexes, fail to open. The index\nisn't described when upserting text records, which don't need it.",
+			Type:        config.ParameterTypeString,
+			Validations: []config.Validation{},
+		},
+		DestinationConfigInvalidIDOriginalKeyField: {
+			Default:     "",
+			Description: "OriginalKeyField is the metadata key holding the original ID of the\nvectors whose ID is replaced, so that it's still searchable. The\noriginal ID isn't stored if empty.",
+			Type:        config.ParameterTypeString,
+			Validations: []config.Validation{},
+		},
+		DestinationConfigInvalidIDPolicy: {
+			Default:     "reject",
+			Description: "Policy is one of \"reject\", failing the record, \"sha256\", replacing the\nID with its hex encoded SHA-256 hash, or \"uuid5\", replacing the ID with\na UUIDv5 within uuidNamespace.",
+			Type:        config.ParameterTypeString,
+			Validations: []config.Validation{
+				config.ValidationInclusion{List: []string{"reject", "sha256", "uuid5"}},
+			},
+		},
+		DestinationConfigInvalidIDUuidNamespace: {
+			Default:     "6ba7b811-9dad-11d1-80b4-00c04fd430c8",
+			Description: "UUIDNamespace is the namespace of the UUIDv5 IDs.",
+			Type:        config.ParameterTypeString,
+			Validations: []config.Validation{},
+		},
+		DestinationConfigMaxRequestBytes: {
+			Default:     "2097152",
+			Description: "MaxRequestBytes is the maximum estimated size in bytes of a single\nupsert or delete request. Larger batches are split in multiple requests.",
+			Type:        config.ParameterTypeInt,
+			Validations: []config.Validation{
+				config.ValidationGreaterThan{V: 0},
+			},
+		},
+		DestinationConfigMaxRequestVectors: {
+			Default:     "1000",
+			Description: "MaxRequestVectors is the maximum number of vectors upserted or deleted\nin a single request. Larger batches are split in multiple requests.",
+			Type:        config.ParameterTypeInt,
+			Validations: []config.Validation{
+				config.ValidationGreaterThan{V: 0},
+			},
+		},
+		DestinationConfigMetadataCoerce: {
+			Default:     "",
+			Description: "Coerce is the list of \"key:type\" rules converting string metadata\nvalues, like the record metadata, into typed values. Types are\n\"number\", \"boolean\", \"list\" (a list of strings split by\nlistDelimiter) and \"timestamp\" (an RFC3339 timestamp converted to unix\nseconds). Keys are the ones after renaming.",
+			Type:        config.ParameterTypeString,
+			Validations: []config.Validation{},
+		},
+		DestinationConfigMetadataCoerceMode: {
+			Default:     "strict",
+			Description: "CoerceMode is either \"strict\", failing the record if a value can't be\ncoerced, or \"lenient\", keeping the original string.",
+			Type:        config.ParameterTypeString,
+			Validations: []config.Validation{
+				config.ValidationInclusion{List: []string{"strict", "lenient"}},
+			},
+		},
+		DestinationConfigMetadataDropInternal: {
+			Default:     "",
+			Description: "DropInternal removes the record metadata keys starting with \"opencdc.\"\nor \"conduit.\", which are added by Conduit.",
+			Type:        config.ParameterTypeBool,
+			Validations: []config.Validation{},
+		},
+		DestinationConfigMetadataDropKeys: {
+			Default:     "",
+			Description: "DropKeys is the list of low priority metadata keys removed, in order,\nuntil the metadata fits in maxSize. Required by the drop policy.",
+			Type:        config.ParameterTypeString,
+			Validations: []config.Validation{},
+		},
+		DestinationConfigMetadataExclude: {
+			Default:     "",
+			Description: "Exclude is the list of patterns of the record metadata keys to remove,\nwith the same syntax as include.",
+			Type:        config.ParameterTypeString,
+			Validations: []config.Validation{},
+		},
+		DestinationConfigMetadataInclude: {
+			Default:     "",
+			Description: "Include is the list of patterns of the record metadata keys to keep.\nAll keys are kept if empty. Patterns are globs, like \"doc.*\", or regular\nexpressions prefixed with \"regex:\", like \"regex:^doc\\.[a-z]+$\".",
+			Type:        config.ParameterTypeString,
+			Validations: []config.Validation{},
+		},
+		DestinationConfigMetadataListDelimiter: {
+			Default:     ",",
+			Description: "ListDelimiter is the delimiter of the values coerced into lists.",
+			Type:        config.ParameterTypeString,
+			Validations: []config.Validation{},
+		},
+		DestinationConfigMetadataMaxSize: {
+			Default:     "40960",
+			Description: "MaxSize is the maximum size in bytes of the JSON encoded metadata of a\nvector. Pinecone rejects vectors with more than 40KB of metadata.",
+			Type:        config.ParameterTypeInt,
+			Validations: []config.Validation{
+				config.ValidationGreaterThan{V: 0},
+			},
+		},
+		DestinationConfigMetadataOverflowPolicy: {
+			Default:     "fail",
+			Description: "OverflowPolicy is applied to vectors whose metadata is larger than\nmaxSize. It's one of \"fail\", failing the write, \"truncate\", truncating\nthe largest string values, or \"drop\", removing the keys listed in\ndropKeys in order. There's no policy routing only the oversized records\nto the dead letter queue, as a failed write nacks all the records after\nthe failed one too.",
+			Type:        config.ParameterTypeString,
+			Validations: []config.Validation{
+				config.ValidationInclusion{List: []string{"fail", "truncate", "drop"}},
+			},
+		},
+		DestinationConfigMetadataPayloadFields: {
+			Default:     "",
+			Description: "PayloadFields is the list of payload fields copied into the vector\nmetadata, keeping their JSON type so that they can be used in metadata\nfilters. Fields are dot separated paths or JSON pointers, and are\nwritten under the last segment of their path. Use \"*\" to copy all the\ntop level payload fields except the vector fields.",
+			Type:        config.ParameterTypeString,
+			Validations: []config.Validation{},
+		},
+		DestinationConfigMetadataRename: {
+			Default:     "",
+			Description: "Rename is the list of \"old:new\" rules renaming record metadata keys.\nRules are applied after the keys are filtered.",
+			Type:        config.ParameterTypeString,
+			Validations: []config.Validation{},
+		},
 		DestinationConfigNamespace: {
 			Default:     "",
 			Description: "Namespace is the Pinecone's index namespace. Defaults to the empty\nnamespace. It can contain a [Go template](https://pkg.go.dev/text/template)\nthat will be executed for each record to determine the namespace.",
 			Type:        config.ParameterTypeString,
 			Validations: []config.Validation{},
 		},
+		DestinationConfigRateLimitNamespaceRequestsPerSecond: {
+			Default:     "0",
+			Description: "NamespaceRequestsPerSecond is the maximum number of write requests per\nsecond sent to each namespace, on top of requestsPerSecond.",
+			Type:        config.ParameterTypeFloat,
+			Validations: []config.Validation{
+				config.ValidationGreaterThan{V: -1},
+			},
+		},
+		DestinationConfigRateLimitNamespaceVectorsPerSecond: {
+			Default:     "0",
+			Description: "NamespaceVectorsPerSecond is the maximum number of vectors per second\nupserted or deleted in each namespace, on top of vectorsPerSecond.",
+			Type:        config.ParameterTypeFloat,
+			Validations: []config.Validation{
+				config.ValidationGreaterThan{V: -1},
+			},
+		},
+		DestinationConfigRateLimitRequestsPerSecond: {
+			Default:     "0",
+			Description: "RequestsPerSecond is the maximum number of write requests per second\nsent to the index.",
+			Type:        config.ParameterTypeFloat,
+			Validations: []config.Validation{
+				config.ValidationGreaterThan{V: -1},
+			},
+		},
+		DestinationConfigRateLimitVectorsPerSecond: {
+			Default:     "0",
+			Description: "VectorsPerSecond is the maximum number of vectors per second upserted\nor deleted in the index.",
+			Type:        config.ParameterTypeFloat,
+			Validations: []config.Validation{
+				config.ValidationGreaterThan{V: -1},
+			},
+		},
+		DestinationConfigRecordsBatchSize: {
+			Default:     "96",
+			Description: "BatchSize is the maximum number of records upserted by a single\nrequest. Pinecone accepts up to 96 records per request.",
+			Type:        config.ParameterTypeInt,
+			Validations: []config.Validation{
+				config.ValidationGreaterThan{V: 0},
+			},
+		},
+		DestinationConfigRecordsField: {
+			Default:     "text",
+			Description: "Field is the field holding the text of the record, as a dot separated\npath or a JSON pointer. It's read within each chunk if records are\nfanned out into chunks.",
+			Type:        config.ParameterTypeString,
+			Validations: []config.Validation{},
+		},
+		DestinationConfigRecordsIndexField: {
+			Default:     "chunk_text",
+			Description: "IndexField is the field of the Pinecone records that the index embeds,\nas set in the field map of its embedding configuration.",
+			Type:        config.ParameterTypeString,
+			Validations: []config.Validation{},
+		},
+		DestinationConfigRecordsTimeout: {
+			Default:     "30s",
+			Description: "Timeout is the timeout of a single upsert records request, which\nincludes the embedding of the records. Requests timing out are retried\nlike failed writes.",
+			Type:        config.ParameterTypeDuration,
+			Validations: []config.Validation{},
+		},
+		DestinationConfigRetryInitialBackoff: {
+			Default:     "500ms",
+			Description: "InitialBackoff is the time to wait before the first retry. The time is\ndoubled on every retry, with some jitter.",
+			Type:        config.ParameterTypeDuration,
+			Validations: []config.Validation{},
+		},
+		DestinationConfigRetryMaxBackoff: {
+			Default:     "30s",
+			Description: "MaxBackoff is the maximum time to wait between two retries.",
+			Type:        config.ParameterTypeDuration,
+			Validations: []config.Validation{},
+		},
+		DestinationConfigRetryMaxRetries: {
+			Default:     "5",
+			Description: "MaxRetries is the maximum number of times a request failing with a\nretryable error is retried. Retries are disabled if set to zero.",
+			Type:        config.ParameterTypeInt,
+			Validations: []config.Validation{
+				config.ValidationGreaterThan{V: -1},
+			},
+		},
+		DestinationConfigSparseAvgDocLength: {
+			Default:     "",
+			Description: "AvgDocLength is the average number of tokens of the documents of the\ncorpus. Required if statsFile is empty.",
+			Type:        config.ParameterTypeFloat,
+			Validations: []config.Validation{},
+		},
+		DestinationConfigSparseB: {
+			Default:     "0.75",
+			Description: "B is the BM25 document length normalization parameter.",
+			Type:        config.ParameterTypeFloat,
+			Validations: []config.Validation{},
+		},
+		DestinationConfigSparseEncoder: {
+			Default:     "none",
+			Description: "Encoder is either \"none\", disabling sparse encoding, or \"bm25\", encoding\ntexts like the BM25 encoder of the pinecone-text Python library.",
+			Type:        config.ParameterTypeString,
+			Validations: []config.Validation{
+				config.ValidationInclusion{List: []string{"none", "bm25"}},
+			},
+		},
+		DestinationConfigSparseField: {
+			Default:     "text",
+			Description: "Field is the field holding the text to encode, as a dot separated path\nor a JSON pointer. It's read within each chunk if records are fanned\nout into chunks.",
+			Type:        config.ParameterTypeString,
+			Validations: []config.Validation{},
+		},
+		DestinationConfigSparseK1: {
+			Default:     "1.2",
+			Description: "K1 is the BM25 term frequency saturation parameter.",
+			Type:        config.ParameterTypeFloat,
+			Validations: []config.Validation{},
+		},
+		DestinationConfigSparseStatsFile: {
+			Default:     "",
+			Description: "StatsFile is the path of the corpus statistics dumped by the BM25\nencoder of pinecone-text, holding the average document length, the\nb and k1 parameters and the tokenizer options. They take precedence\nover avgDocLength, b and k1.",
+			Type:        config.ParameterTypeString,
+			Validations: []config.Validation{},
+		},
+		DestinationConfigTlsCaCertFile: {
+			Default:     "",
+			Description: "CACertFile is the path to a PEM encoded CA bundle used to verify the\ncertificate of the host. Defaults to the system CA pool.",
+			Type:        config.ParameterTypeString,
+			Validations: []config.Validation{},
+		},
+		DestinationConfigTlsInsecureSkipVerify: {
+			Default:     "",
+			Description: "InsecureSkipVerify disables the verification of the certificate of the\nhost. It should only be used for testing.",
+			Type:        config.ParameterTypeBool,
+			Validations: []config.Validation{},
+		},
+		DestinationConfigUpdateMode: {
+			Default:     "upsert",
+			Description: "UpdateMode is either \"upsert\", upserting the whole vector of update\nrecords, \"partial\", only updating the metadata of the vector when\nthe payload of an update record has no vector values, or the same\nvalues as the payload before, or \"update\", updating the metadata of\nthe vector and its values if the payload has any.",
+			Type:        config.ParameterTypeString,
+			Validations: []config.Validation{
+				config.ValidationInclusion{List: []string{"upsert", "partial", "update"}},
+			},
+		},
+		DestinationConfigUpsertMode: {
+			Default:     "vectors",
+			Description: "UpsertMode is either \"vectors\", upserting the vectors of the records,\nor \"records\", upserting text records into an index with integrated\nembedding, which embeds them. Delete records delete vectors either way.",
+			Type:        config.ParameterTypeString,
+			Validations: []config.Validation{
+				config.ValidationInclusion{List: []string{"vectors", "records"}},
+			},
+		},
 	}
 }
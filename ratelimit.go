@@ -0,0 +1,132 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pinecone
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+
+	"golang.org/x/time/rate"
+)
+
+// RateLimitConfig contains the client side limits of the write requests. A
+// limit set to zero is disabled.
+type RateLimitConfig struct {
+	// RequestsPerSecond is the maximum number of write requests per second
+	// sent to the index.
+	RequestsPerSecond float64 `json:"requestsPerSecond" default:"0" validate:"gt=-1"`
+
+	// VectorsPerSecond is the maximum number of vectors per second upserted
+	// or deleted in the index.
+	VectorsPerSecond float64 `json:"vectorsPerSecond" default:"0" validate:"gt=-1"`
+
+	// NamespaceRequestsPerSecond is the maximum number of write requests per
+	// second sent to each namespace, on top of requestsPerSecond.
+	NamespaceRequestsPerSecond float64 `json:"namespaceRequestsPerSecond" default:"0" validate:"gt=-1"`
+
+	// NamespaceVectorsPerSecond is the maximum number of vectors per second
+	// upserted or deleted in each namespace, on top of vectorsPerSecond.
+	NamespaceVectorsPerSecond float64 `json:"namespaceVectorsPerSecond" default:"0" validate:"gt=-1"`
+}
+
+// rateLimiters are the token buckets of a single scope, nil when disabled.
+type rateLimiters struct {
+	requests *rate.Limiter
+	vectors  *rate.Limiter
+}
+
+// writeLimiter smooths the write requests, with token buckets for the index
+// and for each namespace.
+type writeLimiter struct {
+	config RateLimitConfig
+	// maxVectors is the maximum number of vectors in a request, the vectors
+	// buckets need to hold at least that many tokens.
+	maxVectors int
+
+	index rateLimiters
+
+	m          sync.Mutex
+	namespaces map[string]rateLimiters
+}
+
+// newWriteLimiter returns nil if no limit is configured.
+func newWriteLimiter(config RateLimitConfig, maxVectors int) *writeLimiter {
+	if config == (RateLimitConfig{}) {
+		return nil
+	}
+
+	l := &writeLimiter{
+		config:     config,
+		maxVectors: maxVectors,
+		namespaces: make(map[string]rateLimiters),
+	}
+	l.index = l.newRateLimiters(config.RequestsPerSecond, config.VectorsPerSecond)
+
+	return l
+}
+
+func (l *writeLimiter) newRateLimiters(requestsPerSecond, vectorsPerSecond float64) rateLimiters {
+	var limiters rateLimiters
+	if requestsPerSecond > 0 {
+		burst := int(math.Ceil(requestsPerSecond))
+		limiters.requests = rate.NewLimiter(rate.Limit(requestsPerSecond), burst)
+	}
+	if vectorsPerSecond > 0 {
+		burst := max(int(math.Ceil(vectorsPerSecond)), l.maxVectors)
+		limiters.vectors = rate.NewLimiter(rate.Limit(vectorsPerSecond), burst)
+	}
+	return limiters
+}
+
+func (l *writeLimiter) namespace(namespace string) rateLimiters {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	limiters, ok := l.namespaces[namespace]
+	if !ok {
+		limiters = l.newRateLimiters(l.config.NamespaceRequestsPerSecond, l.config.NamespaceVectorsPerSecond)
+		l.namespaces[namespace] = limiters
+	}
+	return limiters
+}
+
+// wait blocks until a request with the given number of vectors can be sent to
+// the namespace, or the context is done.
+func (l *writeLimiter) wait(ctx context.Context, namespace string, vectors int) error {
+	if l == nil {
+		return nil
+	}
+
+	if err := l.index.wait(ctx, vectors); err != nil {
+		return err
+	}
+	return l.namespace(namespace).wait(ctx, vectors)
+}
+
+func (r rateLimiters) wait(ctx context.Context, vectors int) error {
+	if r.requests != nil {
+		if err := r.requests.Wait(ctx); err != nil {
+			return fmt.Errorf("failed to wait for request rate limit: %w", err)
+		}
+	}
+	if r.vectors != nil {
+		if err := r.vectors.WaitN(ctx, vectors); err != nil {
+			return fmt.Errorf("failed to wait for vector rate limit: %w", err)
+		}
+	}
+	return nil
+}
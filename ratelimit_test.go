@@ -0,0 +1,71 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pinecone
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestWriteLimiter(t *testing.T) {
+	// waiting for longer than the deadline fails right away
+	shortCtx := func(t *testing.T) context.Context {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		t.Cleanup(cancel)
+		return ctx
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		is := is.New(t)
+
+		l := newWriteLimiter(RateLimitConfig{}, 100)
+		is.Equal(l, nil)
+		is.NoErr(l.wait(shortCtx(t), "ns", 100))
+	})
+
+	t.Run("vectors per second", func(t *testing.T) {
+		is := is.New(t)
+
+		l := newWriteLimiter(RateLimitConfig{VectorsPerSecond: 10}, 100)
+
+		// the bucket holds at least a full request
+		is.NoErr(l.wait(shortCtx(t), "ns", 100))
+		is.True(l.wait(shortCtx(t), "ns", 5) != nil)
+	})
+
+	t.Run("requests per namespace", func(t *testing.T) {
+		is := is.New(t)
+
+		l := newWriteLimiter(RateLimitConfig{NamespaceRequestsPerSecond: 1}, 100)
+
+		is.NoErr(l.wait(shortCtx(t), "ns1", 1))
+		is.True(l.wait(shortCtx(t), "ns1", 1) != nil)
+
+		// other namespaces have their own bucket
+		is.NoErr(l.wait(shortCtx(t), "ns2", 1))
+	})
+
+	t.Run("requests per index", func(t *testing.T) {
+		is := is.New(t)
+
+		l := newWriteLimiter(RateLimitConfig{RequestsPerSecond: 1}, 100)
+
+		is.NoErr(l.wait(shortCtx(t), "ns1", 1))
+		is.True(l.wait(shortCtx(t), "ns2", 1) != nil)
+	})
+}
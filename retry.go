@@ -0,0 +1,108 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pinecone
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	sdk "github.com/conduitio/conduit-connector-sdk"
+	"github.com/jpillora/backoff"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// RetryConfig contains the settings used to retry failed write requests.
+type RetryConfig struct {
+	// MaxRetries is the maximum number of times a request failing with a
+	// retryable error is retried. Retries are disabled if set to zero.
+	MaxRetries int `json:"maxRetries" default:"5" validate:"gt=-1"`
+
+	// InitialBackoff is the time to wait before the first retry. The time is
+	// doubled on every retry, with some jitter.
+	InitialBackoff time.Duration `json:"initialBackoff" default:"500ms"`
+
+	// MaxBackoff is the maximum time to wait between two retries.
+	MaxBackoff time.Duration `json:"maxBackoff" default:"30s"`
+}
+
+// isRetryable returns whether the error is transient, so that the request can
+// be sent again as is.
+func isRetryable(err error) bool {
+	// errors of other APIs, like the embedding ones, tell it themselves
+	var retryable interface{ retryable() bool }
+	if errors.As(err, &retryable) {
+		return retryable.retryable()
+	}
+
+	st, ok := status.FromError(err)
+	if !ok {
+		return false
+	}
+
+	//nolint:exhaustive // all other codes are not retryable
+	switch st.Code() {
+	case codes.Unavailable, codes.ResourceExhausted, codes.DeadlineExceeded, codes.Aborted:
+		return true
+	default:
+		return false
+	}
+}
+
+// withRetries calls send until it succeeds, fails with an error that isn't
+// retryable or the retries are exhausted. The ids are the IDs of the vectors
+// in the request, added to the returned error. Requests without IDs, like
+// deletes by filter, are described by the callers wrapping the error.
+func withRetries(ctx context.Context, cfg RetryConfig, ids []string, send func(context.Context) error) error {
+	var vectors string
+	if len(ids) > 0 {
+		vectors = fmt.Sprintf(" for vectors %v", ids)
+	}
+
+	b := &backoff.Backoff{
+		Min:    cfg.InitialBackoff,
+		Max:    cfg.MaxBackoff,
+		Factor: 2,
+		Jitter: true,
+	}
+
+	for {
+		err := send(ctx)
+		if err == nil {
+			return nil
+		}
+
+		if !isRetryable(err) {
+			return fmt.Errorf("non-retryable error%s: %w", vectors, err)
+		}
+		if int(b.Attempt()) >= cfg.MaxRetries {
+			return fmt.Errorf("retries exhausted after %d attempts%s: %w", int(b.Attempt())+1, vectors, err)
+		}
+
+		wait := b.Duration()
+		sdk.Logger(ctx).Warn().Err(err).
+			Dur("backoff", wait).
+			Int("attempt", int(b.Attempt())).
+			Msg("retryable error, retrying")
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("context done while retrying: %w", ctx.Err())
+		case <-time.After(wait):
+		}
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pinecone
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/matryer/is"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestIsRetryable(t *testing.T) {
+	testCases := []struct {
+		err  error
+		want bool
+	}{
+		{err: status.Error(codes.Unavailable, ""), want: true},
+		{err: status.Error(codes.ResourceExhausted, ""), want: true},
+		{err: status.Error(codes.DeadlineExceeded, ""), want: true},
+		{err: status.Error(codes.Aborted, ""), want: true},
+		{err: status.Error(codes.InvalidArgument, ""), want: false},
+		{err: status.Error(codes.PermissionDenied, ""), want: false},
+		{err: status.Error(codes.Unauthenticated, ""), want: false},
+		{err: errors.New("not a grpc error"), want: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.err.Error(), func(t *testing.T) {
+			is := is.New(t)
+			is.Equal(isRetryable(tc.err), tc.want)
+		})
+	}
+}
+
+func TestWithRetries_Error(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	failure := status.Error(codes.InvalidArgument, "bad request")
+	send := func(context.Context) error { return failure }
+
+	err := withRetries(ctx, RetryConfig{}, []string{"id1", "id2"}, send)
+	is.True(errors.Is(err, failure))
+	is.Equal(err.Error(), "non-retryable error for vectors [id1 id2]: "+failure.Error())
+
+	// requests without vector IDs don't list them
+	err = withRetries(ctx, RetryConfig{}, nil, send)
+	is.Equal(err.Error(), "non-retryable error: "+failure.Error())
+
+	retryable := status.Error(codes.Unavailable, "unavailable")
+	err = withRetries(ctx, RetryConfig{}, nil, func(context.Context) error { return retryable })
+	is.Equal(err.Error(), "retries exhausted after 1 attempts: "+retryable.Error())
+}
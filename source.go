@@ -0,0 +1,366 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pinecone
+
+//go:generate paramgen -output=paramgen_src.go SourceConfig
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/conduitio/conduit-commons/config"
+	"github.com/conduitio/conduit-commons/opencdc"
+	sdk "github.com/conduitio/conduit-connector-sdk"
+	"github.com/pinecone-io/go-pinecone/pinecone"
+)
+
+type Source struct {
+	sdk.UnimplementedSource
+
+	config SourceConfig
+
+	// snapshot is nil once the snapshot is done and change detection has
+	// taken over, or if the source was restarted in change detection mode.
+	snapshot *snapshotIterator
+
+	// cdc is nil if change detection is disabled.
+	cdc *cdcIterator
+
+	// lastPosition is the position of the last record returned by Read.
+	lastPosition opencdc.Position
+}
+
+type SourceConfig struct {
+	// APIKey is the API Key for authenticating with Pinecone.
+	APIKey string `json:"apiKey" validate:"required"`
+
+	// Host is the whole Pinecone index host URL.
+	Host string `json:"host" validate:"required"`
+
+	// TLS contains the settings used to connect to https hosts. Hosts with
+	// the http scheme, like the Pinecone Local emulator, are connected to over
+	// plaintext.
+	TLS TLSConfig `json:"tls"`
+
+	// Namespaces is the list of Pinecone index namespaces to read, in order.
+	// If empty, all namespaces reported by the index stats will be read.
+	Namespaces []string `json:"namespaces"`
+
+	// Prefix limits the read vectors to those whose ID starts with the
+	// given prefix.
+	Prefix string `json:"prefix"`
+
+	// PageSize is the number of vector IDs listed and fetched per request.
+	PageSize int `json:"pageSize" default:"100" validate:"gt=0,lt=101"`
+
+	// PollingPeriod is the time between two polls of the namespaces once the
+	// initial snapshot is done. Each poll compares the listed vectors with
+	// the previous ones and emits create, update and delete records. Change
+	// detection is disabled if set to zero.
+	PollingPeriod time.Duration `json:"pollingPeriod" default:"0s"`
+
+	// StateFile is the path of the local file where the vector hashes used
+	// for change detection are stored. Required if pollingPeriod is set.
+	StateFile string `json:"stateFile"`
+}
+
+func (c SourceConfig) toMap() map[string]string {
+	return map[string]string{
+		"apiKey":                 c.APIKey,
+		"host":                   c.Host,
+		"tls.caCertFile":         c.TLS.CACertFile,
+		"tls.insecureSkipVerify": fmt.Sprint(c.TLS.InsecureSkipVerify),
+		"namespaces":             strings.Join(c.Namespaces, ","),
+		"prefix":                 c.Prefix,
+		"pageSize":               fmt.Sprint(c.PageSize),
+		"pollingPeriod":          c.PollingPeriod.String(),
+		"stateFile":              c.StateFile,
+	}
+}
+
+func NewSource() sdk.Source {
+	return sdk.SourceWithMiddleware(&Source{}, sdk.DefaultSourceMiddleware()...)
+}
+
+func (s *Source) Parameters() config.Parameters {
+	return s.config.Parameters()
+}
+
+func (s *Source) Configure(ctx context.Context, cfg config.Config) error {
+	if err := sdk.Util.ParseConfig(ctx, cfg, &s.config, s.Parameters()); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+	if err := validateConnection(s.config.Host, s.config.TLS); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+
+	if s.config.PollingPeriod > 0 && s.config.StateFile == "" {
+		return errors.New("invalid config: stateFile is required when pollingPeriod is set")
+	}
+
+	sdk.Logger(ctx).Info().Msg("configured pinecone source")
+
+	return nil
+}
+
+func (s *Source) Open(ctx context.Context, sdkPos opencdc.Position) error {
+	pos, err := parseSourcePosition(sdkPos)
+	if err != nil {
+		return err
+	}
+
+	pageSize := uint32(s.config.PageSize) //nolint:gosec // validated to be in (0, 100]
+
+	if s.config.PollingPeriod > 0 {
+		s.cdc, err = newCDCIterator(ctx, cdcIteratorParams{
+			apiKey:     s.config.APIKey,
+			host:       s.config.Host,
+			tls:        s.config.TLS,
+			namespaces: s.config.Namespaces,
+			prefix:     s.config.Prefix,
+			pageSize:   pageSize,
+			period:     s.config.PollingPeriod,
+			stateFile:  s.config.StateFile,
+			position:   pos,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create change detection iterator: %w", err)
+		}
+	}
+
+	if pos != nil && pos.Mode == positionModeCDC {
+		if s.cdc == nil {
+			return errors.New("position points to change detection, but pollingPeriod is not set")
+		}
+
+		sdk.Logger(ctx).Info().Msg("opened pinecone source in change detection mode")
+		return nil
+	}
+
+	params := snapshotIteratorParams{
+		apiKey:     s.config.APIKey,
+		host:       s.config.Host,
+		tls:        s.config.TLS,
+		namespaces: s.config.Namespaces,
+		prefix:     s.config.Prefix,
+		pageSize:   pageSize,
+		position:   pos,
+	}
+	if s.cdc != nil {
+		params.state = s.cdc.snapshotState(pos)
+		params.checkpoint = s.cdc.addSnapshotPage
+	}
+
+	s.snapshot, err = newSnapshotIterator(ctx, params)
+	if err != nil {
+		return fmt.Errorf("failed to create snapshot iterator: %w", err)
+	}
+
+	sdk.Logger(ctx).Info().Msg("opened pinecone source")
+
+	return nil
+}
+
+func (s *Source) Read(ctx context.Context) (opencdc.Record, error) {
+	rec, err := s.read(ctx)
+	switch {
+	case errors.Is(err, errSnapshotDone), errors.Is(err, errNoChanges):
+		return opencdc.Record{}, sdk.ErrBackoffRetry
+	case err != nil:
+		return opencdc.Record{}, fmt.Errorf("failed to read next record: %w", err)
+	}
+
+	s.lastPosition = rec.Position
+	return rec, nil
+}
+
+func (s *Source) read(ctx context.Context) (opencdc.Record, error) {
+	if s.snapshot != nil {
+		rec, err := s.snapshot.next(ctx)
+		if !errors.Is(err, errSnapshotDone) || s.cdc == nil {
+			return rec, err
+		}
+
+		sdk.Logger(ctx).Info().Msg("snapshot done, starting change detection")
+
+		if err := s.cdc.startFrom(s.snapshot.state, s.lastPosition); err != nil {
+			return opencdc.Record{}, err
+		}
+		if err := s.snapshot.close(); err != nil {
+			return opencdc.Record{}, err
+		}
+		s.snapshot = nil
+	}
+
+	return s.cdc.next(ctx)
+}
+
+func (s *Source) Ack(ctx context.Context, position opencdc.Position) error {
+	sdk.Logger(ctx).Trace().Str("position", string(position)).Msg("got ack")
+
+	if s.cdc == nil {
+		return nil
+	}
+
+	if err := s.cdc.ack(position); err != nil {
+		return fmt.Errorf("failed to ack position: %w", err)
+	}
+	return nil
+}
+
+func (s *Source) Teardown(_ context.Context) error {
+	var err error
+	if s.snapshot != nil {
+		if closeErr := s.snapshot.close(); closeErr != nil {
+			err = fmt.Errorf("failed to close iterator: %w", closeErr)
+		}
+	}
+	if s.cdc != nil {
+		if closeErr := s.cdc.close(); closeErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to close change detection iterator: %w", closeErr))
+		}
+	}
+	return err
+}
+
+// sourcePosition points to the last read vector. Pinecone only paginates
+// vector listings, so the position stores the pagination token of the page
+// that contained the vector together with its ID, which allows resuming in
+// the middle of a page.
+//
+// In change detection mode the pagination token is meaningless, and the
+// polling cycle is stored instead to tell positions of different polls apart.
+type sourcePosition struct {
+	Mode            positionMode `json:"mode"`
+	Namespace       string       `json:"namespace"`
+	PaginationToken *string      `json:"paginationToken,omitempty"`
+	LastID          string       `json:"lastID"`
+	Cycle           int          `json:"cycle,omitempty"`
+}
+
+type positionMode string
+
+const (
+	positionModeSnapshot positionMode = "snapshot"
+	positionModeCDC      positionMode = "cdc"
+)
+
+func parseSourcePosition(sdkPos opencdc.Position) (*sourcePosition, error) {
+	if sdkPos == nil {
+		return nil, nil //nolint:nilnil // a nil position means starting from scratch
+	}
+
+	var pos sourcePosition
+	if err := json.Unmarshal(sdkPos, &pos); err != nil {
+		return nil, fmt.Errorf("failed to parse position: %w", err)
+	}
+
+	return &pos, nil
+}
+
+func (p sourcePosition) toSDKPosition() opencdc.Position {
+	bs, err := json.Marshal(p)
+	if err != nil {
+		// should never happen, the position only contains strings
+		panic(fmt.Errorf("failed to marshal position: %w", err))
+	}
+
+	return bs
+}
+
+// vectorToRecord builds a record with the given operation out of the vector.
+// The record has the same shape that the destination expects with the default
+// field mapping, so that records read from an index can be written as-is into
+// another one.
+func vectorToRecord(
+	vec *pinecone.Vector, namespace string,
+	op opencdc.Operation, pos sourcePosition,
+) (opencdc.Record, error) {
+	payload, err := vectorPayload(vec)
+	if err != nil {
+		return opencdc.Record{}, err
+	}
+
+	metadata, err := vectorMetadataToRecord(vec.Metadata)
+	if err != nil {
+		return opencdc.Record{}, fmt.Errorf("failed to convert vector %s metadata: %w", vec.Id, err)
+	}
+	metadata.SetCollection(namespace)
+
+	key := opencdc.RawData(vec.Id)
+	switch op { //nolint:exhaustive // deletes don't have a vector, see deletedVectorRecord
+	case opencdc.OperationCreate:
+		return sdk.Util.Source.NewRecordCreate(pos.toSDKPosition(), metadata, key, opencdc.RawData(payload)), nil
+	case opencdc.OperationUpdate:
+		return sdk.Util.Source.NewRecordUpdate(pos.toSDKPosition(), metadata, key, nil, opencdc.RawData(payload)), nil
+	default:
+		return sdk.Util.Source.NewRecordSnapshot(pos.toSDKPosition(), metadata, key, opencdc.RawData(payload)), nil
+	}
+}
+
+// deletedVectorRecord builds a delete record for a vector that is no longer in
+// the given namespace.
+func deletedVectorRecord(id, namespace string, pos sourcePosition) opencdc.Record {
+	metadata := make(opencdc.Metadata)
+	metadata.SetCollection(namespace)
+
+	return sdk.Util.Source.NewRecordDelete(pos.toSDKPosition(), metadata, opencdc.RawData(id), nil)
+}
+
+func vectorPayload(vec *pinecone.Vector) ([]byte, error) {
+	vectorValues := pineconeVectorValues{Values: vec.Values}
+	if vec.SparseValues != nil {
+		vectorValues.SparseValues = &sparseValues{
+			Indices: vec.SparseValues.Indices,
+			Values:  vec.SparseValues.Values,
+		}
+	}
+
+	payload, err := json.Marshal(vectorValues)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal vector %s values: %w", vec.Id, err)
+	}
+
+	return payload, nil
+}
+
+// vectorMetadataToRecord converts the Pinecone vector metadata into OpenCDC
+// metadata. String values are copied as-is, the rest are JSON encoded given
+// that OpenCDC metadata only supports string values.
+func vectorMetadataToRecord(vecMetadata *pinecone.Metadata) (opencdc.Metadata, error) {
+	metadata := make(opencdc.Metadata)
+	if vecMetadata == nil {
+		return metadata, nil
+	}
+
+	for key, value := range vecMetadata.AsMap() {
+		if s, ok := value.(string); ok {
+			metadata[key] = s
+			continue
+		}
+
+		bs, err := json.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal metadata key %s: %w", key, err)
+		}
+		metadata[key] = string(bs)
+	}
+
+	return metadata, nil
+}
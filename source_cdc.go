@@ -0,0 +1,513 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pinecone
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/conduitio/conduit-commons/opencdc"
+	sdk "github.com/conduitio/conduit-connector-sdk"
+	"github.com/pinecone-io/go-pinecone/pinecone"
+)
+
+var errNoChanges = errors.New("no changes")
+
+type cdcIteratorParams struct {
+	apiKey, host string
+	tls          TLSConfig
+
+	namespaces []string
+	prefix     string
+	pageSize   uint32
+
+	period    time.Duration
+	stateFile string
+
+	// position is the position to resume from, nil if not resuming from a
+	// change detection position.
+	position *sourcePosition
+}
+
+// cdcIterator detects changes by periodically listing and fetching all
+// vectors in the namespaces, comparing each vector hash with the one seen in
+// the previous poll.
+//
+// The state of a poll is only persisted in the state file once the last record
+// emitted by that poll is acked, so that after a restart the changes of a
+// partially acked poll are detected again. The state of the poll is saved as
+// pending before its changes are emitted, so that the changes read before the
+// restart are only emitted again if the vectors changed since.
+//
+// The connections to the namespaces are kept open across polls, and closed by
+// close.
+type cdcIterator struct {
+	apiKey, host string
+	tls          TLSConfig
+	namespaces   []string
+	prefix       string
+	pageSize     uint32
+
+	// indexes are the connections to the polled namespaces, by namespace.
+	// The connection to the default namespace lists the namespaces when
+	// they aren't configured.
+	indexes map[string]*pinecone.IndexConnection
+
+	period    time.Duration
+	stateFile string
+
+	lastPoll time.Time
+	buffer   []opencdc.Record
+
+	// state is the state of the last poll, changes are detected against it.
+	state *vectorState
+
+	// resuming is set until the first poll after a restart in the middle of
+	// a poll, whose state is saved even if it detects no changes.
+	resuming bool
+
+	checkpointsM sync.Mutex
+	// checkpoints are the states of polls waiting for their last record to be
+	// acked before being saved, in poll order.
+	checkpoints []stateCheckpoint
+	// lastAcked is the last acked position. The last snapshot record can be
+	// acked before the snapshot state is known to be complete.
+	lastAcked opencdc.Position
+	// snapshotAcked contains the hashes of the acked snapshot vectors, saved
+	// as the snapshot pages are acked so that a restart in the middle of the
+	// snapshot doesn't lose them.
+	snapshotAcked *vectorState
+	// saved is the state last saved in the state file, and pending the state
+	// of the last poll with changes until it's saved, nil if it is.
+	saved   *vectorState
+	pending *vectorState
+}
+
+type stateCheckpoint struct {
+	position opencdc.Position
+	state    *vectorState
+	// snapshotPage is set if the state only contains the hashes of a page of
+	// snapshot vectors, which are added to the acked snapshot state.
+	snapshotPage bool
+}
+
+func newCDCIterator(ctx context.Context, params cdcIteratorParams) (*cdcIterator, error) {
+	state, err := loadVectorState(params.stateFile)
+	if err != nil {
+		return nil, err
+	}
+	pending := state.Pending
+	state.Pending = nil
+
+	it := &cdcIterator{
+		apiKey:     params.apiKey,
+		host:       params.host,
+		tls:        params.tls,
+		namespaces: params.namespaces,
+		prefix:     params.prefix,
+		pageSize:   params.pageSize,
+		period:     params.period,
+		stateFile:  params.stateFile,
+		state:      state,
+		saved:      state,
+		pending:    pending,
+		indexes:    make(map[string]*pinecone.IndexConnection),
+	}
+
+	// discovered namespaces are listed through the default namespace, and
+	// connected to on their first poll
+	namespaces := params.namespaces
+	if len(namespaces) == 0 {
+		namespaces = []string{""}
+	}
+	for _, namespace := range namespaces {
+		if _, err := it.index(ctx, namespace); err != nil {
+			return nil, errors.Join(err, it.close())
+		}
+	}
+
+	if pos := params.position; pos != nil && pos.Mode == positionModeCDC && pending != nil && pos.Cycle == pending.Cycle {
+		it.state = it.readState(state, pending, *pos)
+		it.resuming = true
+	}
+
+	return it, nil
+}
+
+// index returns the connection to the namespace, opening it if needed.
+func (it *cdcIterator) index(ctx context.Context, namespace string) (*pinecone.IndexConnection, error) {
+	if index, ok := it.indexes[namespace]; ok {
+		return index, nil
+	}
+
+	index, err := newIndex(ctx, newIndexParams{
+		apiKey:    it.apiKey,
+		host:      it.host,
+		tls:       it.tls,
+		namespace: namespace,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create index for namespace %s: %w", namespace, err)
+	}
+	it.indexes[namespace] = index
+	return index, nil
+}
+
+// close closes the connections to the namespaces.
+func (it *cdcIterator) close() error {
+	var err error
+	for namespace, index := range it.indexes {
+		if closeErr := index.Close(); closeErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to close index for namespace %s: %w", namespace, closeErr))
+		}
+	}
+	it.indexes = make(map[string]*pinecone.IndexConnection)
+	return err
+}
+
+// snapshotState returns the state the snapshot collects the hashes into. A
+// snapshot resumed from the given position continues the loaded state, which
+// contains the hashes saved as its pages were acked, otherwise it starts from
+// scratch.
+func (it *cdcIterator) snapshotState(position *sourcePosition) *vectorState {
+	if position == nil {
+		it.state = newVectorState(0)
+	}
+
+	it.checkpointsM.Lock()
+	defer it.checkpointsM.Unlock()
+
+	it.snapshotAcked = newVectorState(0)
+	it.snapshotAcked.merge(it.state)
+
+	return it.state
+}
+
+// addSnapshotPage adds the hashes of a page of snapshot vectors to the saved
+// state once the given position, the one of the last record of the page, is
+// acked.
+func (it *cdcIterator) addSnapshotPage(position opencdc.Position, hashes *vectorState) error {
+	return it.enqueue(stateCheckpoint{
+		position:     position,
+		state:        hashes,
+		snapshotPage: true,
+	})
+}
+
+// startFrom replaces the loaded state with the state collected by the
+// snapshot. The state is persisted once the given position, the one of the
+// last snapshot record, is acked.
+func (it *cdcIterator) startFrom(state *vectorState, lastPosition opencdc.Position) error {
+	it.state = state
+	it.lastPoll = time.Now()
+
+	return it.addCheckpoint(lastPosition, state)
+}
+
+// next returns the next change, polling the namespaces at most once. Polls
+// can take longer than the period, so polling until a change is found could
+// never return.
+func (it *cdcIterator) next(ctx context.Context) (opencdc.Record, error) {
+	if len(it.buffer) == 0 {
+		if time.Since(it.lastPoll) < it.period {
+			return opencdc.Record{}, errNoChanges
+		}
+
+		if err := it.poll(ctx); err != nil {
+			return opencdc.Record{}, err
+		}
+		if len(it.buffer) == 0 {
+			return opencdc.Record{}, errNoChanges
+		}
+	}
+
+	rec := it.buffer[0]
+	it.buffer = it.buffer[1:]
+
+	return rec, nil
+}
+
+// poll reads all vectors and fills the buffer with the changes since the
+// previous poll.
+func (it *cdcIterator) poll(ctx context.Context) error {
+	it.lastPoll = time.Now()
+
+	namespaces := it.namespaces
+	if len(namespaces) == 0 {
+		index, err := it.index(ctx, "")
+		if err != nil {
+			return err
+		}
+		if namespaces, err = describeNamespaces(ctx, index); err != nil {
+			return err
+		}
+
+		// namespaces that disappeared need to be checked for deletes too
+		for namespace := range it.state.Namespaces {
+			if !slices.Contains(namespaces, namespace) {
+				namespaces = append(namespaces, namespace)
+			}
+		}
+		// changes are emitted in the same order by every poll
+		slices.Sort(namespaces)
+	}
+
+	state := newVectorState(it.state.Cycle + 1)
+	var changes []opencdc.Record
+	for _, namespace := range namespaces {
+		nsChanges, err := it.pollNamespace(ctx, namespace, state)
+		if err != nil {
+			return err
+		}
+		changes = append(changes, nsChanges...)
+	}
+
+	sdk.Logger(ctx).Debug().
+		Int("cycle", state.Cycle).
+		Int("changes", len(changes)).
+		Msg("polled pinecone namespaces")
+
+	resuming := it.resuming
+	it.resuming = false
+
+	it.state = state
+	if len(changes) == 0 {
+		if resuming {
+			// the remaining changes of the interrupted poll were all read
+			return it.addCheckpoint(nil, state)
+		}
+		return nil
+	}
+
+	if err := it.savePending(state); err != nil {
+		return err
+	}
+	it.buffer = changes
+	return it.addCheckpoint(changes[len(changes)-1].Position, state)
+}
+
+// pollNamespace lists and fetches all vectors in the namespace, adding their
+// hashes into the given state. It returns the records for the vectors that
+// changed since the previous poll.
+func (it *cdcIterator) pollNamespace(
+	ctx context.Context, namespace string, state *vectorState,
+) ([]opencdc.Record, error) {
+	index, err := it.index(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	position := func(id string) sourcePosition {
+		return sourcePosition{
+			Mode:      positionModeCDC,
+			Namespace: namespace,
+			LastID:    id,
+			Cycle:     state.Cycle,
+		}
+	}
+
+	var changes []opencdc.Record
+	var token *string
+	for {
+		ids, nextToken, err := listVectorIDs(ctx, index, it.prefix, it.pageSize, token)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list vectors in namespace %s: %w", namespace, err)
+		}
+
+		if len(ids) > 0 {
+			res, err := index.FetchVectors(ctx, ids)
+			if err != nil {
+				return nil, fmt.Errorf("failed to fetch vectors in namespace %s: %w", namespace, err)
+			}
+
+			for _, id := range ids {
+				vec, ok := res.Vectors[id]
+				if !ok {
+					// deleted between listing and fetching
+					continue
+				}
+
+				hash, err := vectorHash(vec)
+				if err != nil {
+					return nil, err
+				}
+				state.set(namespace, id, hash)
+
+				prevHash, seen := it.state.get(namespace, id)
+				if seen && prevHash == hash {
+					continue
+				}
+
+				op := opencdc.OperationCreate
+				if seen {
+					op = opencdc.OperationUpdate
+				}
+
+				rec, err := vectorToRecord(vec, namespace, op, position(id))
+				if err != nil {
+					return nil, err
+				}
+				changes = append(changes, rec)
+			}
+		}
+
+		if nextToken == nil {
+			break
+		}
+		token = nextToken
+	}
+
+	for _, id := range slices.Sorted(maps.Keys(it.state.Namespaces[namespace])) {
+		if _, ok := state.get(namespace, id); !ok {
+			changes = append(changes, deletedVectorRecord(id, namespace, position(id)))
+		}
+	}
+
+	return changes, nil
+}
+
+// readState returns the state of the vectors as emitted before a restart in
+// the middle of a poll: the saved state updated with the pending state of the
+// interrupted poll, for the changes up to and including the given position.
+// Polling against it detects the changes that weren't read before the
+// restart, and the ones that happened since.
+func (it *cdcIterator) readState(saved, pending *vectorState, pos sourcePosition) *vectorState {
+	state := newVectorState(pending.Cycle - 1)
+	state.merge(saved)
+
+	// the vector at the position was emitted as deleted if it's not pending
+	_, posUpserted := pending.get(pos.Namespace, pos.LastID)
+	read := func(namespace, id string, deleted bool) bool {
+		return it.compareChanges(namespace, id, deleted, pos.Namespace, pos.LastID, !posUpserted) <= 0
+	}
+
+	for namespace, hashes := range pending.Namespaces {
+		for id, hash := range hashes {
+			if read(namespace, id, false) {
+				state.set(namespace, id, hash)
+			}
+		}
+	}
+	for namespace, hashes := range saved.Namespaces {
+		for id := range hashes {
+			if _, ok := pending.get(namespace, id); !ok && read(namespace, id, true) {
+				state.remove(namespace, id)
+			}
+		}
+	}
+
+	return state
+}
+
+// compareChanges compares two changes in the order they're emitted by a
+// poll: by namespace, then the created and updated vectors, listed by ID,
+// then the deleted ones, sorted by ID.
+func (it *cdcIterator) compareChanges(
+	namespaceA, idA string, deletedA bool,
+	namespaceB, idB string, deletedB bool,
+) int {
+	if namespaceA != namespaceB {
+		if len(it.namespaces) == 0 {
+			return cmp.Compare(namespaceA, namespaceB)
+		}
+		return cmp.Compare(slices.Index(it.namespaces, namespaceA), slices.Index(it.namespaces, namespaceB))
+	}
+	if deletedA != deletedB {
+		if deletedA {
+			return 1
+		}
+		return -1
+	}
+	return cmp.Compare(idA, idB)
+}
+
+func (it *cdcIterator) addCheckpoint(position opencdc.Position, state *vectorState) error {
+	return it.enqueue(stateCheckpoint{
+		position: position,
+		state:    state,
+	})
+}
+
+func (it *cdcIterator) enqueue(checkpoint stateCheckpoint) error {
+	it.checkpointsM.Lock()
+	defer it.checkpointsM.Unlock()
+
+	if checkpoint.position == nil || bytes.Equal(checkpoint.position, it.lastAcked) {
+		// nothing to wait for
+		return it.save(checkpoint)
+	}
+
+	it.checkpoints = append(it.checkpoints, checkpoint)
+	return nil
+}
+
+// ack persists the states of the oldest pending checkpoints if the given
+// position is the one of their last record.
+func (it *cdcIterator) ack(position opencdc.Position) error {
+	it.checkpointsM.Lock()
+	defer it.checkpointsM.Unlock()
+
+	it.lastAcked = position
+	// the last snapshot page and the snapshot itself share their last record
+	for len(it.checkpoints) > 0 && bytes.Equal(it.checkpoints[0].position, position) {
+		if err := it.save(it.checkpoints[0]); err != nil {
+			return err
+		}
+		it.checkpoints = it.checkpoints[1:]
+	}
+
+	return nil
+}
+
+// save persists the state of the checkpoint. It must be called with the
+// checkpoints lock held.
+func (it *cdcIterator) save(checkpoint stateCheckpoint) error {
+	state := checkpoint.state
+	if checkpoint.snapshotPage {
+		it.snapshotAcked.merge(state)
+		state = it.snapshotAcked
+	}
+
+	if it.pending != nil && it.pending.Cycle <= state.Cycle {
+		it.pending = nil
+	}
+	it.saved = state
+	return it.write()
+}
+
+// savePending saves the state of a poll as pending, before its changes are
+// emitted.
+func (it *cdcIterator) savePending(state *vectorState) error {
+	it.checkpointsM.Lock()
+	defer it.checkpointsM.Unlock()
+
+	it.pending = state
+	return it.write()
+}
+
+// write writes the saved state into the state file, along with the pending
+// state. It must be called with the checkpoints lock held.
+func (it *cdcIterator) write() error {
+	state := *it.saved
+	state.Pending = it.pending
+	return state.save(it.stateFile)
+}
@@ -0,0 +1,285 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pinecone
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/conduitio/conduit-commons/opencdc"
+	sdk "github.com/conduitio/conduit-connector-sdk"
+	"github.com/google/uuid"
+	"github.com/matryer/is"
+)
+
+func srcConfigFromEnv(t *testing.T, namespace string) SourceConfig {
+	destCfg := destConfigFromEnv(t)
+	return SourceConfig{
+		APIKey:     destCfg.APIKey,
+		Host:       destCfg.Host,
+		Namespaces: []string{namespace},
+		PageSize:   2,
+	}
+}
+
+// writeTestRecords writes the given records into the namespace using the
+// destination.
+func writeTestRecords(ctx context.Context, t *testing.T, is *is.I, namespace string, recs []opencdc.Record) {
+	destCfg := destConfigFromEnv(t)
+	destCfg.Namespace = namespace
+
+	dest := NewDestination()
+	is.NoErr(dest.Configure(ctx, destCfg.toMap()))
+	is.NoErr(dest.Open(ctx))
+	defer teardown(ctx, is, dest)
+
+	written, err := dest.Write(ctx, recs)
+	is.NoErr(err)
+	is.Equal(written, len(recs))
+}
+
+func openTestSource(ctx context.Context, is *is.I, cfg SourceConfig, pos opencdc.Position) *Source {
+	src := &Source{}
+	is.NoErr(src.Configure(ctx, cfg.toMap()))
+	is.NoErr(src.Open(ctx, pos))
+	return src
+}
+
+// readAll reads records until the source has nothing more to return.
+func readAll(ctx context.Context, is *is.I, src *Source) []opencdc.Record {
+	var recs []opencdc.Record
+	for {
+		rec, err := src.Read(ctx)
+		if errors.Is(err, sdk.ErrBackoffRetry) {
+			return recs
+		}
+		is.NoErr(err)
+		is.NoErr(src.Ack(ctx, rec.Position))
+
+		recs = append(recs, rec)
+	}
+}
+
+func recordKeys(recs []opencdc.Record) []string {
+	keys := make([]string, len(recs))
+	for i, rec := range recs {
+		keys[i] = string(rec.Key.Bytes())
+	}
+	return keys
+}
+
+func TestSource_Snapshot(t *testing.T) {
+	ctx := context.Background()
+	is := is.New(t)
+	namespace := fmt.Sprintf("test-source%s", uuid.NewString()[:8])
+
+	var written []opencdc.Record
+	for range 5 {
+		written = append(written, testRecords(opencdc.OperationCreate)...)
+	}
+	writeTestRecords(ctx, t, is, namespace, written)
+
+	destCfg := destConfigFromEnv(t)
+	destCfg.Namespace = namespace
+	index := createIndex(is, destCfg)
+	defer deleteAllRecords(is, index)
+
+	cfg := srcConfigFromEnv(t, namespace)
+
+	src := openTestSource(ctx, is, cfg, nil)
+	recs := readAll(ctx, is, src)
+	is.NoErr(src.Teardown(ctx))
+
+	is.Equal(len(recs), len(written))
+	for _, rec := range recs {
+		is.Equal(rec.Operation, opencdc.OperationSnapshot)
+
+		collection, err := rec.Metadata.GetCollection()
+		is.NoErr(err)
+		is.Equal(collection, namespace)
+
+		_, err = defaultVectorParser.parse(ctx, rec)
+		is.NoErr(err)
+	}
+
+	t.Run("resumes from position", func(t *testing.T) {
+		is := is.New(t)
+
+		// resume from the middle of a page
+		resumeIdx := 2
+		src := openTestSource(ctx, is, cfg, recs[resumeIdx].Position)
+		resumed := readAll(ctx, is, src)
+		is.NoErr(src.Teardown(ctx))
+
+		is.Equal(recordKeys(resumed), recordKeys(recs[resumeIdx+1:]))
+	})
+}
+
+func TestSource_ChangeDetection(t *testing.T) {
+	ctx := context.Background()
+	is := is.New(t)
+	namespace := fmt.Sprintf("test-source%s", uuid.NewString()[:8])
+
+	var initial []opencdc.Record
+	for range 3 {
+		initial = append(initial, testRecords(opencdc.OperationCreate)...)
+	}
+	writeTestRecords(ctx, t, is, namespace, initial)
+
+	destCfg := destConfigFromEnv(t)
+	destCfg.Namespace = namespace
+	index := createIndex(is, destCfg)
+	defer deleteAllRecords(is, index)
+
+	cfg := srcConfigFromEnv(t, namespace)
+	cfg.PollingPeriod = time.Millisecond
+	cfg.StateFile = filepath.Join(t.TempDir(), "state.json")
+
+	src := openTestSource(ctx, is, cfg, nil)
+	snapshot := readAll(ctx, is, src)
+	is.Equal(len(snapshot), len(initial))
+
+	// first poll right after the snapshot doesn't detect anything
+	time.Sleep(cfg.PollingPeriod)
+	is.Equal(len(readAll(ctx, is, src)), 0)
+
+	created := testRecords(opencdc.OperationCreate)[0]
+	updated := initial[0]
+	updated.Metadata = opencdc.Metadata{"prop": "changed"}
+	writeTestRecords(ctx, t, is, namespace, []opencdc.Record{created, updated})
+
+	deleted := initial[len(initial)-1]
+	is.NoErr(index.DeleteVectorsById(ctx, []string{string(deleted.Key.Bytes())}))
+
+	time.Sleep(cfg.PollingPeriod)
+	changes := readAll(ctx, is, src)
+	is.NoErr(src.Teardown(ctx))
+
+	ops := make(map[string]opencdc.Operation)
+	for _, rec := range changes {
+		ops[string(rec.Key.Bytes())] = rec.Operation
+	}
+	is.Equal(ops[string(created.Key.Bytes())], opencdc.OperationCreate)
+	is.Equal(ops[string(updated.Key.Bytes())], opencdc.OperationUpdate)
+	is.Equal(ops[string(deleted.Key.Bytes())], opencdc.OperationDelete)
+	is.Equal(len(changes), 3)
+
+	t.Run("restart keeps the acked state", func(t *testing.T) {
+		is := is.New(t)
+
+		src := openTestSource(ctx, is, cfg, changes[len(changes)-1].Position)
+		is.Equal(len(readAll(ctx, is, src)), 0)
+		is.NoErr(src.Teardown(ctx))
+	})
+}
+
+func TestSource_ChangeDetection_RestartDuringPoll(t *testing.T) {
+	ctx := context.Background()
+	is := is.New(t)
+	namespace := fmt.Sprintf("test-source%s", uuid.NewString()[:8])
+
+	record := func(key, prop string) opencdc.Record {
+		rec := testRecords(opencdc.OperationCreate)[0]
+		rec.Key = opencdc.RawData(key)
+		rec.Metadata = opencdc.Metadata{"prop": prop}
+		return rec
+	}
+	writeTestRecords(ctx, t, is, namespace, []opencdc.Record{record("b", "initial"), record("d", "initial")})
+
+	destCfg := destConfigFromEnv(t)
+	destCfg.Namespace = namespace
+	index := createIndex(is, destCfg)
+	defer deleteAllRecords(is, index)
+
+	cfg := srcConfigFromEnv(t, namespace)
+	cfg.PollingPeriod = time.Millisecond
+	cfg.StateFile = filepath.Join(t.TempDir(), "state.json")
+
+	src := openTestSource(ctx, is, cfg, nil)
+	is.Equal(len(readAll(ctx, is, src)), 2)
+
+	// stop after the first change of the poll
+	writeTestRecords(ctx, t, is, namespace, []opencdc.Record{record("b", "updated"), record("d", "updated")})
+	time.Sleep(cfg.PollingPeriod)
+	rec, err := src.Read(ctx)
+	is.NoErr(err)
+	is.Equal(string(rec.Key.Bytes()), "b")
+	is.NoErr(src.Ack(ctx, rec.Position))
+	is.NoErr(src.Teardown(ctx))
+
+	// vectors sorting before the last read one change while stopped
+	writeTestRecords(ctx, t, is, namespace, []opencdc.Record{record("a", "created"), record("b", "updated again")})
+
+	src = openTestSource(ctx, is, cfg, rec.Position)
+	defer func() { is.NoErr(src.Teardown(ctx)) }()
+
+	time.Sleep(cfg.PollingPeriod)
+	changes := readAll(ctx, is, src)
+
+	props := make(map[string]string)
+	for _, rec := range changes {
+		props[string(rec.Key.Bytes())] = rec.Metadata["prop"]
+	}
+	is.Equal(props, map[string]string{"a": "created", "b": "updated again", "d": "updated"})
+
+	time.Sleep(cfg.PollingPeriod)
+	is.Equal(len(readAll(ctx, is, src)), 0)
+}
+
+func TestSource_ChangeDetection_RestartDuringSnapshot(t *testing.T) {
+	ctx := context.Background()
+	is := is.New(t)
+	namespace := fmt.Sprintf("test-source%s", uuid.NewString()[:8])
+
+	var initial []opencdc.Record
+	for range 3 {
+		initial = append(initial, testRecords(opencdc.OperationCreate)...)
+	}
+	writeTestRecords(ctx, t, is, namespace, initial)
+
+	destCfg := destConfigFromEnv(t)
+	destCfg.Namespace = namespace
+	index := createIndex(is, destCfg)
+	defer deleteAllRecords(is, index)
+
+	cfg := srcConfigFromEnv(t, namespace)
+	cfg.PollingPeriod = time.Millisecond
+	cfg.StateFile = filepath.Join(t.TempDir(), "state.json")
+
+	// stop in the middle of the second page
+	src := openTestSource(ctx, is, cfg, nil)
+	var read []opencdc.Record
+	for range cfg.PageSize + 1 {
+		rec, err := src.Read(ctx)
+		is.NoErr(err)
+		is.NoErr(src.Ack(ctx, rec.Position))
+		read = append(read, rec)
+	}
+	is.NoErr(src.Teardown(ctx))
+
+	src = openTestSource(ctx, is, cfg, read[len(read)-1].Position)
+	defer func() { is.NoErr(src.Teardown(ctx)) }()
+
+	resumed := readAll(ctx, is, src)
+	is.Equal(len(read)+len(resumed), len(initial))
+
+	// the vectors read before the restart aren't detected as created
+	time.Sleep(cfg.PollingPeriod)
+	is.Equal(len(readAll(ctx, is, src)), 0)
+}
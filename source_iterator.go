@@ -0,0 +1,338 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pinecone
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+
+	"github.com/conduitio/conduit-commons/opencdc"
+	sdk "github.com/conduitio/conduit-connector-sdk"
+	"github.com/pinecone-io/go-pinecone/pinecone"
+)
+
+var errSnapshotDone = errors.New("snapshot done")
+
+type snapshotIteratorParams struct {
+	apiKey, host string
+	tls          TLSConfig
+
+	namespaces []string
+	prefix     string
+	pageSize   uint32
+
+	// position is the position to resume from, nil if starting from scratch.
+	position *sourcePosition
+
+	// state collects the hash of every read vector, which is needed to detect
+	// changes once the snapshot is done. It's nil if not tracked.
+	state *vectorState
+	// checkpoint is called with the hashes of every page of vectors and the
+	// position of its last record, nil if the state isn't tracked.
+	checkpoint func(position opencdc.Position, hashes *vectorState) error
+}
+
+// snapshotIterator reads all vectors from the given namespaces, one namespace
+// after the other. Vectors are read in pages: the page IDs are listed by
+// prefix and then fetched all at once.
+type snapshotIterator struct {
+	apiKey, host string
+	tls          TLSConfig
+	prefix       string
+	pageSize     uint32
+
+	namespaces   []string
+	namespaceIdx int
+
+	// index is the connection to the namespace currently being read.
+	index *pinecone.IndexConnection
+
+	// pageToken is the token of the next page to list, nil on the first page
+	// of a namespace.
+	pageToken *string
+
+	// resumeAfter is the ID of the last vector read before a restart. Vectors
+	// up to and including it are skipped from the first listed page.
+	resumeAfter string
+
+	buffer []opencdc.Record
+
+	// state contains the hashes of the read vectors, nil if not tracked.
+	state      *vectorState
+	checkpoint func(position opencdc.Position, hashes *vectorState) error
+}
+
+func newSnapshotIterator(ctx context.Context, params snapshotIteratorParams) (*snapshotIterator, error) {
+	namespaces := params.namespaces
+	discovered := len(namespaces) == 0
+	if discovered {
+		var err error
+		namespaces, err = listNamespaces(ctx, newIndexParams{
+			apiKey: params.apiKey,
+			host:   params.host,
+			tls:    params.tls,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	it := &snapshotIterator{
+		apiKey:     params.apiKey,
+		host:       params.host,
+		tls:        params.tls,
+		prefix:     params.prefix,
+		pageSize:   params.pageSize,
+		namespaces: namespaces,
+		state:      params.state,
+		checkpoint: params.checkpoint,
+	}
+
+	if pos := params.position; pos != nil {
+		idx := slices.Index(namespaces, pos.Namespace)
+		switch {
+		case idx != -1:
+			it.namespaceIdx = idx
+			it.pageToken = pos.PaginationToken
+			it.resumeAfter = pos.LastID
+		case discovered:
+			// The namespace might have been emptied since the position was
+			// recorded. Discovered namespaces are sorted, so we can continue
+			// with the following one.
+			it.namespaceIdx = sort.SearchStrings(namespaces, pos.Namespace)
+		default:
+			return nil, fmt.Errorf("namespace %q from position is not configured", pos.Namespace)
+		}
+	}
+
+	return it, nil
+}
+
+// listNamespaces returns the sorted list of namespaces in the index. The
+// namespace of the given params is ignored.
+func listNamespaces(ctx context.Context, params newIndexParams) ([]string, error) {
+	params.namespace = ""
+	index, err := newIndex(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create index: %w", err)
+	}
+	defer index.Close()
+
+	return describeNamespaces(ctx, index)
+}
+
+// describeNamespaces returns the sorted list of namespaces in the index, out
+// of the stats described through the given connection.
+func describeNamespaces(ctx context.Context, index *pinecone.IndexConnection) ([]string, error) {
+	stats, err := index.DescribeIndexStats(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe index stats: %w", err)
+	}
+
+	namespaces := make([]string, 0, len(stats.Namespaces))
+	for namespace := range stats.Namespaces {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+
+	sdk.Logger(ctx).Info().Strs("namespaces", namespaces).Msg("discovered index namespaces")
+
+	return namespaces, nil
+}
+
+func (it *snapshotIterator) next(ctx context.Context) (opencdc.Record, error) {
+	for len(it.buffer) == 0 {
+		if it.namespaceIdx >= len(it.namespaces) {
+			return opencdc.Record{}, errSnapshotDone
+		}
+
+		if err := it.loadPage(ctx); err != nil {
+			return opencdc.Record{}, err
+		}
+	}
+
+	rec := it.buffer[0]
+	it.buffer = it.buffer[1:]
+
+	return rec, nil
+}
+
+// loadPage lists the next page of vector IDs in the current namespace and
+// fills the buffer with the fetched vectors. It moves on to the next namespace
+// once the last page has been listed.
+func (it *snapshotIterator) loadPage(ctx context.Context) error {
+	namespace := it.namespaces[it.namespaceIdx]
+	if it.index == nil {
+		index, err := newIndex(ctx, newIndexParams{
+			apiKey:    it.apiKey,
+			host:      it.host,
+			tls:       it.tls,
+			namespace: namespace,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create index for namespace %s: %w", namespace, err)
+		}
+		it.index = index
+	}
+
+	ids, nextToken, err := listVectorIDs(ctx, it.index, it.prefix, it.pageSize, it.pageToken)
+	if err != nil {
+		return fmt.Errorf("failed to list vectors in namespace %s: %w", namespace, err)
+	}
+
+	skip := 0
+	if it.resumeAfter != "" {
+		skip = slices.Index(ids, it.resumeAfter) + 1
+		it.resumeAfter = ""
+	}
+
+	if err := it.fillBuffer(ctx, namespace, ids, skip); err != nil {
+		return err
+	}
+
+	if nextToken == nil {
+		return it.nextNamespace()
+	}
+	it.pageToken = nextToken
+
+	return nil
+}
+
+// listVectorIDs lists a page of vector IDs. The returned token is nil if
+// there are no more pages.
+func listVectorIDs(
+	ctx context.Context, index *pinecone.IndexConnection,
+	prefix string, limit uint32, token *string,
+) ([]string, *string, error) {
+	req := &pinecone.ListVectorsRequest{
+		Limit:           &limit,
+		PaginationToken: token,
+	}
+	if prefix != "" {
+		req.Prefix = &prefix
+	}
+
+	res, err := index.ListVectors(ctx, req)
+	if err != nil {
+		return nil, nil, err //nolint:wrapcheck // wrapped by callers
+	}
+
+	ids := make([]string, 0, len(res.VectorIds))
+	for _, id := range res.VectorIds {
+		if id != nil {
+			ids = append(ids, *id)
+		}
+	}
+
+	nextToken := res.NextPaginationToken
+	if nextToken != nil && *nextToken == "" {
+		nextToken = nil
+	}
+
+	return ids, nextToken, nil
+}
+
+// fillBuffer fetches the vectors and fills the buffer with their records,
+// except for the first skip ones, which were read before a restart. Those are
+// still fetched if the state is tracked, as the hashes of a partially acked
+// page aren't saved.
+func (it *snapshotIterator) fillBuffer(ctx context.Context, namespace string, ids []string, skip int) error {
+	if it.state == nil {
+		ids, skip = ids[skip:], 0
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	res, err := it.index.FetchVectors(ctx, ids)
+	if err != nil {
+		return fmt.Errorf("failed to fetch vectors in namespace %s: %w", namespace, err)
+	}
+
+	hashes := newVectorState(0)
+	var lastPosition opencdc.Position
+
+	// We iterate over the listed IDs rather than the fetched map so that
+	// records keep the listing order, which the position relies on.
+	for i, id := range ids {
+		vec, ok := res.Vectors[id]
+		if !ok {
+			// deleted between listing and fetching
+			continue
+		}
+
+		if it.state != nil {
+			hash, err := vectorHash(vec)
+			if err != nil {
+				return err
+			}
+			it.state.set(namespace, id, hash)
+			hashes.set(namespace, id, hash)
+		}
+		if i < skip {
+			continue
+		}
+
+		rec, err := vectorToRecord(vec, namespace, opencdc.OperationSnapshot, sourcePosition{
+			Mode:            positionModeSnapshot,
+			Namespace:       namespace,
+			PaginationToken: it.pageToken,
+			LastID:          id,
+		})
+		if err != nil {
+			return err
+		}
+
+		it.buffer = append(it.buffer, rec)
+		lastPosition = rec.Position
+	}
+
+	if it.checkpoint == nil {
+		return nil
+	}
+	// the hashes of skipped vectors are saved right away, without records
+	// there's nothing to wait for
+	return it.checkpoint(lastPosition, hashes)
+}
+
+func (it *snapshotIterator) nextNamespace() error {
+	it.namespaceIdx++
+	it.pageToken = nil
+
+	if it.index == nil {
+		return nil
+	}
+
+	err := it.index.Close()
+	it.index = nil
+	if err != nil {
+		return fmt.Errorf("failed to close index: %w", err)
+	}
+	return nil
+}
+
+func (it *snapshotIterator) close() error {
+	if it.index == nil {
+		return nil
+	}
+
+	if err := it.index.Close(); err != nil {
+		return fmt.Errorf("failed to close index: %w", err)
+	}
+	return nil
+}
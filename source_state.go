@@ -0,0 +1,139 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pinecone
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"github.com/pinecone-io/go-pinecone/pinecone"
+)
+
+// vectorState is the content hash of every vector seen in a given polling
+// cycle, grouped by namespace. Pinecone has no change feed, so the source
+// compares the state of two consecutive cycles to detect changes.
+type vectorState struct {
+	Cycle      int                          `json:"cycle"`
+	Namespaces map[string]map[string]string `json:"namespaces"`
+
+	// Pending is the state of the poll whose changes are being emitted, saved
+	// before they are so that a restarted poll can tell which ones were
+	// already read. It's nil if there is no such poll.
+	Pending *vectorState `json:"pending,omitempty"`
+}
+
+func newVectorState(cycle int) *vectorState {
+	return &vectorState{
+		Cycle:      cycle,
+		Namespaces: make(map[string]map[string]string),
+	}
+}
+
+func (s *vectorState) set(namespace, id, hash string) {
+	hashes, ok := s.Namespaces[namespace]
+	if !ok {
+		hashes = make(map[string]string)
+		s.Namespaces[namespace] = hashes
+	}
+	hashes[id] = hash
+}
+
+func (s *vectorState) remove(namespace, id string) {
+	delete(s.Namespaces[namespace], id)
+}
+
+func (s *vectorState) get(namespace, id string) (string, bool) {
+	hash, ok := s.Namespaces[namespace][id]
+	return hash, ok
+}
+
+// merge adds the hashes of the other state, overwriting the existing ones.
+func (s *vectorState) merge(other *vectorState) {
+	for namespace, hashes := range other.Namespaces {
+		for id, hash := range hashes {
+			s.set(namespace, id, hash)
+		}
+	}
+}
+
+// loadVectorState reads the state stored in the given file. An empty state is
+// returned if the file doesn't exist yet.
+func loadVectorState(path string) (*vectorState, error) {
+	bs, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return newVectorState(0), nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read state file %s: %w", path, err)
+	}
+
+	state := newVectorState(0)
+	if err := json.Unmarshal(bs, state); err != nil {
+		return nil, fmt.Errorf("failed to parse state file %s: %w", path, err)
+	}
+
+	return state, nil
+}
+
+// save writes the state into the given file. The state is written to a
+// temporary file first so that a crash never leaves a half-written state.
+func (s *vectorState) save(path string) error {
+	bs, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("failed to marshal state: %w", err)
+	}
+
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, bs, 0o600); err != nil {
+		return fmt.Errorf("failed to write state file %s: %w", tmpPath, err)
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("failed to rename state file %s: %w", tmpPath, err)
+	}
+
+	return nil
+}
+
+// vectorHash returns the content hash of the given vector, computed from the
+// same payload and metadata that end up in the record.
+func vectorHash(vec *pinecone.Vector) (string, error) {
+	payload, err := vectorPayload(vec)
+	if err != nil {
+		return "", err
+	}
+
+	var metadata map[string]any
+	if vec.Metadata != nil {
+		metadata = vec.Metadata.AsMap()
+	}
+
+	// json.Marshal sorts map keys, so the encoding is deterministic.
+	bs, err := json.Marshal(metadata)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal vector %s metadata: %w", vec.Id, err)
+	}
+
+	h := sha256.New()
+	h.Write(payload)
+	h.Write([]byte{0})
+	h.Write(bs)
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
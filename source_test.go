@@ -0,0 +1,247 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pinecone
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/conduitio/conduit-commons/config"
+	"github.com/conduitio/conduit-commons/opencdc"
+	"github.com/matryer/is"
+	"github.com/pinecone-io/go-pinecone/pinecone"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestSource_Configure(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		is := is.New(t)
+		src := &Source{}
+
+		err := src.Configure(context.Background(), config.Config{
+			"apiKey": "key",
+			"host":   "https://index.pinecone.io",
+		})
+		is.NoErr(err)
+
+		is.Equal(len(src.config.Namespaces), 0)
+		is.Equal(src.config.PageSize, 100)
+	})
+
+	t.Run("multiple namespaces", func(t *testing.T) {
+		is := is.New(t)
+		src := &Source{}
+
+		err := src.Configure(context.Background(), config.Config{
+			"apiKey":     "key",
+			"host":       "https://index.pinecone.io",
+			"namespaces": "ns1,ns2",
+		})
+		is.NoErr(err)
+
+		is.Equal(src.config.Namespaces, []string{"ns1", "ns2"})
+	})
+
+	t.Run("polling requires state file", func(t *testing.T) {
+		is := is.New(t)
+		src := &Source{}
+
+		err := src.Configure(context.Background(), config.Config{
+			"apiKey":        "key",
+			"host":          "https://index.pinecone.io",
+			"pollingPeriod": "10s",
+		})
+		is.True(err != nil)
+	})
+}
+
+func TestSourcePosition(t *testing.T) {
+	is := is.New(t)
+
+	token := "token"
+	pos := sourcePosition{
+		Mode:            positionModeSnapshot,
+		Namespace:       "namespace",
+		PaginationToken: &token,
+		LastID:          "id",
+	}
+
+	parsed, err := parseSourcePosition(pos.toSDKPosition())
+	is.NoErr(err)
+	is.Equal(*parsed, pos)
+
+	parsed, err = parseSourcePosition(nil)
+	is.NoErr(err)
+	is.Equal(parsed, nil)
+}
+
+func TestVectorToRecord(t *testing.T) {
+	is := is.New(t)
+
+	metadata, err := structpb.NewStruct(map[string]any{
+		"prop1": "val1",
+		"prop2": 2,
+		"prop3": true,
+	})
+	is.NoErr(err)
+
+	vec := &pinecone.Vector{
+		//revive:disable-next-line
+		Id:     "key1",
+		Values: []float32{1, 2},
+		SparseValues: &pinecone.SparseValues{
+			Indices: []uint32{3, 5},
+			Values:  []float32{0.5, 0.3},
+		},
+		Metadata: metadata,
+	}
+
+	rec, err := vectorToRecord(vec, "namespace", opencdc.OperationSnapshot, sourcePosition{
+		Mode:      positionModeSnapshot,
+		Namespace: "namespace",
+		LastID:    "key1",
+	})
+	is.NoErr(err)
+
+	is.Equal(rec.Operation, opencdc.OperationSnapshot)
+	is.Equal(rec.Metadata["prop1"], "val1")
+	is.Equal(rec.Metadata["prop2"], "2")
+	is.Equal(rec.Metadata["prop3"], "true")
+
+	collection, err := rec.Metadata.GetCollection()
+	is.NoErr(err)
+	is.Equal(collection, "namespace")
+
+	// the destination must be able to write the record as-is
+	parsed, err := defaultVectorParser.parse(context.Background(), rec)
+	is.NoErr(err)
+
+	is.Equal(parsed.Id, vec.Id)
+	is.Equal(parsed.Values, vec.Values)
+	is.Equal(parsed.SparseValues, vec.SparseValues)
+}
+
+func TestVectorState_SaveLoad(t *testing.T) {
+	is := is.New(t)
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	state, err := loadVectorState(path)
+	is.NoErr(err)
+	is.Equal(state.Cycle, 0)
+	is.Equal(len(state.Namespaces), 0)
+
+	state = newVectorState(3)
+	state.set("ns1", "id1", "hash1")
+	state.set("ns2", "id2", "hash2")
+	is.NoErr(state.save(path))
+
+	loaded, err := loadVectorState(path)
+	is.NoErr(err)
+	is.Equal(loaded, state)
+}
+
+func TestVectorHash(t *testing.T) {
+	is := is.New(t)
+
+	newVec := func(value float32, prop string) *pinecone.Vector {
+		metadata, err := structpb.NewStruct(map[string]any{"prop": prop})
+		is.NoErr(err)
+
+		//revive:disable-next-line
+		return &pinecone.Vector{Id: "id", Values: []float32{value}, Metadata: metadata}
+	}
+
+	hash1, err := vectorHash(newVec(1, "a"))
+	is.NoErr(err)
+	hash2, err := vectorHash(newVec(1, "a"))
+	is.NoErr(err)
+	is.Equal(hash1, hash2)
+
+	hash3, err := vectorHash(newVec(2, "a"))
+	is.NoErr(err)
+	is.True(hash1 != hash3) // values changed
+
+	hash4, err := vectorHash(newVec(1, "b"))
+	is.NoErr(err)
+	is.True(hash1 != hash4) // metadata changed
+}
+
+func TestCDCIterator_Ack(t *testing.T) {
+	is := is.New(t)
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	// the checkpoints don't need connections to the index
+	it := &cdcIterator{stateFile: path, state: newVectorState(0)}
+
+	state1 := newVectorState(1)
+	state1.set("ns", "id1", "hash1")
+	pos1 := sourcePosition{Mode: positionModeCDC, Namespace: "ns", LastID: "id1", Cycle: 1}.toSDKPosition()
+	is.NoErr(it.addCheckpoint(pos1, state1))
+
+	state2 := newVectorState(2)
+	state2.set("ns", "id2", "hash2")
+	pos2 := sourcePosition{Mode: positionModeCDC, Namespace: "ns", LastID: "id2", Cycle: 2}.toSDKPosition()
+	is.NoErr(it.addCheckpoint(pos2, state2))
+
+	// acking a position that isn't the last one of a poll doesn't save anything
+	is.NoErr(it.ack(pos2))
+	loaded, err := loadVectorState(path)
+	is.NoErr(err)
+	is.Equal(loaded.Cycle, 0)
+
+	is.NoErr(it.ack(pos1))
+	loaded, err = loadVectorState(path)
+	is.NoErr(err)
+	is.Equal(loaded, state1)
+
+	is.NoErr(it.ack(pos2))
+	loaded, err = loadVectorState(path)
+	is.NoErr(err)
+	is.Equal(loaded, state2)
+}
+
+func TestCDCIterator_ReadState(t *testing.T) {
+	saved := newVectorState(1)
+	saved.set("ns", "a", "hash1")
+	saved.set("ns", "b", "hash1")
+	saved.set("ns", "c", "hash1")
+
+	// the interrupted poll updated b, created d and deleted a, in that order
+	pending := newVectorState(2)
+	pending.set("ns", "b", "hash2")
+	pending.set("ns", "c", "hash1")
+	pending.set("ns", "d", "hash2")
+
+	testCases := []struct {
+		lastID string
+		want   map[string]string
+	}{
+		{lastID: "b", want: map[string]string{"a": "hash1", "b": "hash2", "c": "hash1"}},
+		{lastID: "d", want: map[string]string{"a": "hash1", "b": "hash2", "c": "hash1", "d": "hash2"}},
+		{lastID: "a", want: map[string]string{"b": "hash2", "c": "hash1", "d": "hash2"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.lastID, func(t *testing.T) {
+			is := is.New(t)
+			it := &cdcIterator{namespaces: []string{"ns"}}
+
+			state := it.readState(saved, pending, sourcePosition{Namespace: "ns", LastID: tc.lastID, Cycle: 2})
+			is.Equal(state.Cycle, 1)
+			is.Equal(state.Namespaces["ns"], tc.want)
+		})
+	}
+}
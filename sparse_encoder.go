@@ -0,0 +1,191 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pinecone
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/pinecone-io/go-pinecone/pinecone"
+)
+
+const (
+	sparseEncoderNone = "none"
+	sparseEncoderBM25 = "bm25"
+)
+
+// SparseConfig configures the encoding of a text field into the sparse values
+// of the vectors that have none, for hybrid search.
+type SparseConfig struct {
+	// Encoder is either "none", disabling sparse encoding, or "bm25", encoding
+	// texts like the BM25 encoder of the pinecone-text Python library.
+	Encoder string `json:"encoder" default:"none" validate:"inclusion=none|bm25"`
+
+	// Field is the field holding the text to encode, as a dot separated path
+	// or a JSON pointer. It's read within each chunk if records are fanned
+	// out into chunks.
+	Field string `json:"field" default:"text"`
+
+	// StatsFile is the path of the corpus statistics dumped by the BM25
+	// encoder of pinecone-text, holding the average document length, the
+	// b and k1 parameters and the tokenizer options. They take precedence
+	// over avgDocLength, b and k1.
+	StatsFile string `json:"statsFile"`
+
+	// AvgDocLength is the average number of tokens of the documents of the
+	// corpus. Required if statsFile is empty.
+	AvgDocLength float64 `json:"avgDocLength"`
+
+	// B is the BM25 document length normalization parameter.
+	B float64 `json:"b" default:"0.75"`
+
+	// K1 is the BM25 term frequency saturation parameter.
+	K1 float64 `json:"k1" default:"1.2"`
+}
+
+func (c SparseConfig) enabled() bool {
+	return c.Encoder != "" && c.Encoder != sparseEncoderNone
+}
+
+// bm25Stats are the corpus statistics dumped by the BM25 encoder of
+// pinecone-text. The document frequencies are only used to encode queries,
+// so they're ignored. Missing tokenizer options default to true.
+type bm25Stats struct {
+	AvgDocLength      float64 `json:"avgdl"`
+	B                 float64 `json:"b"`
+	K1                float64 `json:"k1"`
+	LowerCase         *bool   `json:"lower_case"`
+	RemovePunctuation *bool   `json:"remove_punctuation"`
+	RemoveStopwords   *bool   `json:"remove_stopwords"`
+	Stem              *bool   `json:"stem"`
+	Language          string  `json:"language"`
+}
+
+func loadBM25Stats(path string) (bm25Stats, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return bm25Stats{}, fmt.Errorf("failed to read bm25 stats file: %w", err)
+	}
+
+	var stats bm25Stats
+	if err := json.Unmarshal(data, &stats); err != nil {
+		return bm25Stats{}, fmt.Errorf("failed to parse bm25 stats file %s: %w", path, err)
+	}
+	if stats.Language != "" && stats.Language != "english" {
+		return bm25Stats{}, fmt.Errorf("bm25 stats file %s: unsupported language %q, only english is supported", path, stats.Language)
+	}
+	return stats, nil
+}
+
+// bm25Encoder encodes texts into sparse values like the encode_documents
+// method of the BM25 encoder of pinecone-text: each token is hashed into a
+// sparse index, with its term frequency normalized by the document length as
+// value. The inverse document frequencies are applied to queries instead.
+type bm25Encoder struct {
+	tokenizer    bm25Tokenizer
+	avgDocLength float64
+	b            float64
+	k1           float64
+}
+
+// newSparseEncoder returns nil if sparse encoding is disabled.
+func newSparseEncoder(cfg SparseConfig) (*bm25Encoder, error) {
+	if !cfg.enabled() {
+		return nil, nil //nolint:nilnil // sparse values aren't encoded
+	}
+	if cfg.Encoder != sparseEncoderBM25 {
+		return nil, fmt.Errorf("unknown sparse encoder %q", cfg.Encoder)
+	}
+
+	e := &bm25Encoder{
+		tokenizer: bm25Tokenizer{
+			lowerCase:         true,
+			removePunctuation: true,
+			removeStopwords:   true,
+			stem:              true,
+		},
+		avgDocLength: cfg.AvgDocLength,
+		b:            cfg.B,
+		k1:           cfg.K1,
+	}
+
+	if cfg.StatsFile != "" {
+		stats, err := loadBM25Stats(cfg.StatsFile)
+		if err != nil {
+			return nil, err
+		}
+		e.avgDocLength, e.b, e.k1 = stats.AvgDocLength, stats.B, stats.K1
+		for _, opt := range []struct {
+			dst *bool
+			src *bool
+		}{
+			{&e.tokenizer.lowerCase, stats.LowerCase},
+			{&e.tokenizer.removePunctuation, stats.RemovePunctuation},
+			{&e.tokenizer.removeStopwords, stats.RemoveStopwords},
+			{&e.tokenizer.stem, stats.Stem},
+		} {
+			if opt.src != nil {
+				*opt.dst = *opt.src
+			}
+		}
+	}
+
+	if e.avgDocLength <= 0 {
+		return nil, errors.New("sparse.avgDocLength or sparse.statsFile is required by the bm25 encoder")
+	}
+	if e.b < 0 || e.b > 1 {
+		return nil, fmt.Errorf("bm25 parameter b must be between 0 and 1, got %v", e.b)
+	}
+	if e.k1 < 0 {
+		return nil, fmt.Errorf("bm25 parameter k1 must be positive, got %v", e.k1)
+	}
+	return e, nil
+}
+
+// encode returns the sparse values of the text, sorted by index, or nil if
+// the text has no tokens.
+func (e *bm25Encoder) encode(text string) *pinecone.SparseValues {
+	tokens := e.tokenizer.tokenize(text)
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	// tokens whose hash collide are counted together
+	tf := make(map[uint32]int)
+	for _, token := range tokens {
+		tf[murmur3([]byte(token))]++
+	}
+
+	indices := make([]uint32, 0, len(tf))
+	for index := range tf {
+		indices = append(indices, index)
+	}
+	slices.Sort(indices)
+
+	norm := e.k1 * (1 - e.b + e.b*float64(len(tokens))/e.avgDocLength)
+	values := make([]float32, len(indices))
+	for i, index := range indices {
+		freq := float64(tf[index])
+		values[i] = float32(freq / (norm + freq))
+	}
+
+	return &pinecone.SparseValues{
+		Indices: indices,
+		Values:  values,
+	}
+}
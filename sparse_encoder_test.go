@@ -0,0 +1,96 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pinecone
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestBM25Encoder_Encode(t *testing.T) {
+	is := is.New(t)
+
+	encoder, err := newSparseEncoder(SparseConfig{Encoder: sparseEncoderBM25, AvgDocLength: 4, B: 0.75, K1: 1.2})
+	is.NoErr(err)
+
+	// the stopwords are removed, leaving 4 tokens of which "cat" twice
+	sparse := encoder.encode("The cat sat on the mat with the cats.")
+	is.True(sparse != nil)
+	is.True(slices.IsSorted(sparse.Indices))
+
+	want := map[uint32]float64{
+		murmur3([]byte("cat")): 2 / 3.2,
+		murmur3([]byte("sat")): 1 / 2.2,
+		murmur3([]byte("mat")): 1 / 2.2,
+	}
+	is.Equal(len(sparse.Indices), len(want))
+	for i, index := range sparse.Indices {
+		is.True(math.Abs(float64(sparse.Values[i])-want[index]) < 1e-6)
+	}
+
+	// texts made of stopwords have no sparse values
+	is.Equal(encoder.encode("the and of"), nil)
+}
+
+func TestNewSparseEncoder(t *testing.T) {
+	is := is.New(t)
+
+	encoder, err := newSparseEncoder(SparseConfig{Encoder: sparseEncoderNone})
+	is.NoErr(err)
+	is.Equal(encoder, nil)
+
+	statsFile := filepath.Join(t.TempDir(), "bm25.json")
+	is.NoErr(os.WriteFile(statsFile, []byte(`{
+		"avgdl": 10.5, "b": 0.5, "k1": 1.5, "doc_freq": {"indices": [1], "values": [2]},
+		"n_docs": 3, "lower_case": true, "remove_punctuation": true,
+		"remove_stopwords": true, "stem": false, "language": "english"
+	}`), 0o600))
+
+	// the stats file takes precedence over the parameters
+	encoder, err = newSparseEncoder(SparseConfig{Encoder: sparseEncoderBM25, StatsFile: statsFile, AvgDocLength: 1, B: 0.75, K1: 1.2})
+	is.NoErr(err)
+	is.Equal(encoder.avgDocLength, 10.5)
+	is.Equal(encoder.b, 0.5)
+	is.Equal(encoder.k1, 1.5)
+	is.Equal(encoder.tokenizer, bm25Tokenizer{lowerCase: true, removePunctuation: true, removeStopwords: true})
+	is.Equal(encoder.encode("runners").Indices, []uint32{murmur3([]byte("runners"))})
+
+	germanStats := filepath.Join(t.TempDir(), "bm25.json")
+	is.NoErr(os.WriteFile(germanStats, []byte(`{"avgdl": 10, "b": 0.5, "k1": 1.5, "language": "german"}`), 0o600))
+
+	testCases := []struct {
+		name string
+		cfg  SparseConfig
+	}{
+		{name: "missing average document length", cfg: SparseConfig{Encoder: sparseEncoderBM25, B: 0.75, K1: 1.2}},
+		{name: "b out of range", cfg: SparseConfig{Encoder: sparseEncoderBM25, AvgDocLength: 4, B: 1.5, K1: 1.2}},
+		{name: "negative k1", cfg: SparseConfig{Encoder: sparseEncoderBM25, AvgDocLength: 4, B: 0.75, K1: -1}},
+		{name: "missing stats file", cfg: SparseConfig{Encoder: sparseEncoderBM25, StatsFile: filepath.Join(t.TempDir(), "missing.json")}},
+		{name: "unsupported language", cfg: SparseConfig{Encoder: sparseEncoderBM25, StatsFile: germanStats}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			is := is.New(t)
+			_, err := newSparseEncoder(tc.cfg)
+			is.True(err != nil)
+		})
+	}
+}
@@ -0,0 +1,338 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pinecone
+
+import (
+	"strings"
+)
+
+// stemmerSpecialWords are the words with an irregular stem, including the
+// invariant ones.
+var stemmerSpecialWords = map[string]string{
+	"skis": "ski", "skies": "sky", "dying": "die", "lying": "lie", "tying": "tie",
+	"idly": "idl", "gently": "gentl", "ugly": "ugli", "early": "earli", "only": "onli",
+	"singly": "singl", "sky": "sky", "news": "news", "howe": "howe", "atlas": "atlas",
+	"cosmos": "cosmos", "bias": "bias", "andes": "andes",
+	"inning": "inning", "innings": "inning", "outing": "outing", "outings": "outing",
+	"canning": "canning", "cannings": "canning", "herring": "herring", "herrings": "herring",
+	"earring": "earring", "earrings": "earring",
+	"proceed": "proceed", "proceeds": "proceed", "proceeded": "proceed", "proceeding": "proceed",
+	"exceed": "exceed", "exceeds": "exceed", "exceeded": "exceed", "exceeding": "exceed",
+	"succeed": "succeed", "succeeds": "succeed", "succeeded": "succeed", "succeeding": "succeed",
+}
+
+// stemmer holds the state of the English Snowball (Porter2) stemming of a
+// word, as implemented by the NLTK SnowballStemmer used by the reference BM25
+// encoder of Pinecone. r1 and r2 are the start of the R1 and R2 regions.
+type stemmer struct {
+	word   string
+	r1, r2 int
+}
+
+// stem returns the stem of the lower case word.
+func stem(word string) string {
+	if len(word) <= 2 {
+		return word
+	}
+	if special, ok := stemmerSpecialWords[word]; ok {
+		return special
+	}
+
+	word = strings.NewReplacer("’", "'", "‘", "'", "‛", "'").Replace(word)
+	word = strings.TrimPrefix(word, "'")
+
+	// y is a consonant at the start of the word or after a vowel
+	bs := []byte(word)
+	for i := range bs {
+		if bs[i] == 'y' && (i == 0 || isStemVowel(bs[i-1])) {
+			bs[i] = 'Y'
+		}
+	}
+
+	s := &stemmer{word: string(bs)}
+	s.regions()
+	s.step0()
+	s.step1a()
+	s.step1b()
+	s.step1c()
+	s.step2()
+	s.step3()
+	s.step4()
+	s.step5()
+
+	return strings.ReplaceAll(s.word, "Y", "y")
+}
+
+func isStemVowel(c byte) bool {
+	switch c {
+	case 'a', 'e', 'i', 'o', 'u', 'y':
+		return true
+	default:
+		return false
+	}
+}
+
+// regionStart returns the position after the first non-vowel following a
+// vowel, starting from the position from.
+func regionStart(word string, from int) int {
+	for i := from + 1; i < len(word); i++ {
+		if !isStemVowel(word[i]) && isStemVowel(word[i-1]) {
+			return i + 1
+		}
+	}
+	return len(word)
+}
+
+func (s *stemmer) regions() {
+	switch {
+	case strings.HasPrefix(s.word, "gener"), strings.HasPrefix(s.word, "arsen"):
+		s.r1 = 5
+	case strings.HasPrefix(s.word, "commun"):
+		s.r1 = 6
+	default:
+		s.r1 = regionStart(s.word, 0)
+	}
+	s.r2 = regionStart(s.word, s.r1)
+}
+
+// suffix returns the first of the suffixes that the word ends with, which is
+// the longest if they're sorted by length.
+func (s *stemmer) suffix(suffixes ...string) string {
+	for _, suffix := range suffixes {
+		if strings.HasSuffix(s.word, suffix) {
+			return suffix
+		}
+	}
+	return ""
+}
+
+func (s *stemmer) inR1(suffix string) bool {
+	return len(s.word)-len(suffix) >= s.r1
+}
+
+func (s *stemmer) inR2(suffix string) bool {
+	return len(s.word)-len(suffix) >= s.r2
+}
+
+func (s *stemmer) replace(suffix, replacement string) {
+	s.word = s.word[:len(s.word)-len(suffix)] + replacement
+}
+
+// at returns the byte at the position from the end of the word, or 0 if the
+// word is too short.
+func (s *stemmer) at(fromEnd int) byte {
+	if fromEnd > len(s.word) {
+		return 0
+	}
+	return s.word[len(s.word)-fromEnd]
+}
+
+// endsShortSyllable returns whether the word ends with a short syllable:
+// a non-vowel other than w, x or Y preceded by a vowel preceded by a
+// non-vowel, or a vowel followed by a non-vowel at the start of the word.
+func (s *stemmer) endsShortSyllable() bool {
+	n := len(s.word)
+	switch {
+	case n >= 3:
+		last := s.word[n-1]
+		return !isStemVowel(last) && last != 'w' && last != 'x' && last != 'Y' &&
+			isStemVowel(s.word[n-2]) && !isStemVowel(s.word[n-3])
+	case n == 2:
+		return isStemVowel(s.word[0]) && !isStemVowel(s.word[1])
+	default:
+		return false
+	}
+}
+
+func (s *stemmer) hasVowel(upTo int) bool {
+	for i := range upTo {
+		if isStemVowel(s.word[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *stemmer) step0() {
+	if suffix := s.suffix("'s'", "'s", "'"); suffix != "" {
+		s.replace(suffix, "")
+	}
+}
+
+func (s *stemmer) step1a() {
+	switch suffix := s.suffix("sses", "ied", "ies", "us", "ss", "s"); suffix {
+	case "sses":
+		s.replace(suffix, "ss")
+	case "ied", "ies":
+		if len(s.word) > 4 {
+			s.replace(suffix, "i")
+		} else {
+			s.replace(suffix, "ie")
+		}
+	case "s":
+		// the vowel can't immediately precede the s, as in "gas"
+		if s.hasVowel(len(s.word) - 2) {
+			s.replace(suffix, "")
+		}
+	}
+}
+
+func (s *stemmer) step1b() {
+	suffix := s.suffix("eedly", "ingly", "edly", "eed", "ing", "ed")
+	switch suffix {
+	case "":
+		return
+	case "eedly", "eed":
+		if s.inR1(suffix) {
+			s.replace(suffix, "ee")
+		}
+		return
+	}
+
+	if !s.hasVowel(len(s.word) - len(suffix)) {
+		return
+	}
+	s.replace(suffix, "")
+
+	switch {
+	case s.suffix("at", "bl", "iz") != "":
+		s.word += "e"
+	case s.suffix("bb", "dd", "ff", "gg", "mm", "nn", "pp", "rr", "tt") != "":
+		s.word = s.word[:len(s.word)-1]
+	case s.r1 >= len(s.word) && s.endsShortSyllable():
+		s.word += "e"
+	}
+}
+
+func (s *stemmer) step1c() {
+	last := s.at(1)
+	if len(s.word) > 2 && (last == 'y' || last == 'Y') && !isStemVowel(s.at(2)) {
+		s.word = s.word[:len(s.word)-1] + "i"
+	}
+}
+
+func (s *stemmer) step2() {
+	suffix := s.suffix(
+		"ization", "ational", "fulness", "ousness", "iveness",
+		"tional", "biliti", "lessli",
+		"entli", "ation", "alism", "aliti", "ousli", "iviti", "fulli",
+		"enci", "anci", "abli", "izer", "ator", "alli",
+		"bli", "ogi", "li",
+	)
+	if suffix == "" || !s.inR1(suffix) {
+		return
+	}
+
+	switch suffix {
+	case "tional":
+		s.replace(suffix, "tion")
+	case "enci":
+		s.replace(suffix, "ence")
+	case "anci":
+		s.replace(suffix, "ance")
+	case "abli":
+		s.replace(suffix, "able")
+	case "entli":
+		s.replace(suffix, "ent")
+	case "izer", "ization":
+		s.replace(suffix, "ize")
+	case "ational", "ation", "ator":
+		s.replace(suffix, "ate")
+	case "alism", "aliti", "alli":
+		s.replace(suffix, "al")
+	case "fulness":
+		s.replace(suffix, "ful")
+	case "ousli", "ousness":
+		s.replace(suffix, "ous")
+	case "iveness", "iviti":
+		s.replace(suffix, "ive")
+	case "biliti", "bli":
+		s.replace(suffix, "ble")
+	case "ogi":
+		if s.at(4) == 'l' {
+			s.replace(suffix, "og")
+		}
+	case "fulli":
+		s.replace(suffix, "ful")
+	case "lessli":
+		s.replace(suffix, "less")
+	case "li":
+		if strings.IndexByte("cdeghkmnrt", s.at(3)) >= 0 {
+			s.replace(suffix, "")
+		}
+	}
+}
+
+func (s *stemmer) step3() {
+	suffix := s.suffix("ational", "tional", "alize", "icate", "iciti", "ative", "ical", "ness", "ful")
+	if suffix == "" || !s.inR1(suffix) {
+		return
+	}
+
+	switch suffix {
+	case "tional":
+		s.replace(suffix, "tion")
+	case "ational":
+		s.replace(suffix, "ate")
+	case "alize":
+		s.replace(suffix, "al")
+	case "icate", "iciti", "ical":
+		s.replace(suffix, "ic")
+	case "ful", "ness":
+		s.replace(suffix, "")
+	case "ative":
+		if s.inR2(suffix) {
+			s.replace(suffix, "")
+		}
+	}
+}
+
+func (s *stemmer) step4() {
+	suffix := s.suffix(
+		"ement", "ance", "ence", "able", "ible", "ment", "ant", "ent", "ism",
+		"ate", "iti", "ous", "ive", "ize", "ion", "al", "er", "ic",
+	)
+	if suffix == "" || !s.inR2(suffix) {
+		return
+	}
+
+	if suffix == "ion" {
+		if c := s.at(4); c == 's' || c == 't' {
+			s.replace(suffix, "")
+		}
+		return
+	}
+	s.replace(suffix, "")
+}
+
+func (s *stemmer) step5() {
+	switch {
+	case strings.HasSuffix(s.word, "l"):
+		if s.inR2("l") && s.at(2) == 'l' {
+			s.replace("l", "")
+		}
+	case strings.HasSuffix(s.word, "e"):
+		if s.inR2("e") {
+			s.replace("e", "")
+			return
+		}
+		// the e is kept after a short syllable, as in "hope"
+		if s.inR1("e") && len(s.word) >= 4 &&
+			(isStemVowel(s.at(2)) || strings.IndexByte("wxY", s.at(2)) >= 0 ||
+				!isStemVowel(s.at(3)) || isStemVowel(s.at(4))) {
+			s.replace("e", "")
+		}
+	}
+}
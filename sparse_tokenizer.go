@@ -0,0 +1,197 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pinecone
+
+import (
+	"encoding/binary"
+	"math/bits"
+	"strings"
+	"unicode"
+)
+
+// englishStopwords are the English stopwords of NLTK, removed by the
+// reference BM25 encoder of Pinecone.
+var englishStopwords = func() map[string]bool {
+	words := strings.Fields(`
+		i me my myself we our ours ourselves you you're you've you'll you'd
+		your yours yourself yourselves he him his himself she she's her hers
+		herself it it's its itself they them their theirs themselves what which
+		who whom this that that'll these those am is are was were be been being
+		have has had having do does did doing a an the and but if or because as
+		until while of at by for with about against between into through during
+		before after above below to from up down in out on off over under again
+		further then once here there when where why how all any both each few
+		more most other some such no nor not only own same so than too very s t
+		can will just don don't should should've now d ll m o re ve y ain aren
+		aren't couldn couldn't didn didn't doesn doesn't hadn hadn't hasn hasn't
+		haven haven't isn isn't ma mightn mightn't mustn mustn't needn needn't
+		shan shan't shouldn shouldn't wasn wasn't weren weren't won won't wouldn
+		wouldn't`)
+
+	stopwords := make(map[string]bool, len(words))
+	for _, w := range words {
+		stopwords[w] = true
+	}
+	return stopwords
+}()
+
+// punctuation is the set of ASCII punctuation characters, which are removed
+// when they're a token on their own.
+const punctuation = "!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~"
+
+// contractions are the suffixes split from the words they end, like "do" and
+// "n't" out of "don't".
+var contractions = []string{"n't", "'ll", "'re", "'ve", "'s", "'m", "'d"}
+
+// bm25Tokenizer splits texts into the tokens of the reference BM25 encoder
+// of Pinecone, which uses the NLTK word tokenizer. It follows it closely for
+// common text, splitting punctuation and contractions from the words, but
+// some rare constructs may be tokenized differently.
+type bm25Tokenizer struct {
+	lowerCase         bool
+	removePunctuation bool
+	removeStopwords   bool
+	stem              bool
+}
+
+func (t bm25Tokenizer) tokenize(text string) []string {
+	var tokens []string
+	for _, field := range strings.Fields(text) {
+		for _, token := range splitWords(field) {
+			if t.lowerCase {
+				token = strings.ToLower(token)
+			}
+			if t.removePunctuation && len(token) == 1 && strings.Contains(punctuation, token) {
+				continue
+			}
+			if t.removeStopwords && englishStopwords[token] {
+				continue
+			}
+			if t.stem {
+				token = stem(strings.ToLower(token))
+			}
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens
+}
+
+// splitWords splits a whitespace separated field into words and punctuation
+// tokens.
+func splitWords(field string) []string {
+	var tokens []string
+	var word strings.Builder
+	flush := func() {
+		if word.Len() > 0 {
+			tokens = append(tokens, splitContraction(word.String())...)
+			word.Reset()
+		}
+	}
+
+	runes := []rune(field)
+	for i, r := range runes {
+		switch {
+		case r == '"':
+			// double quotes are turned into opening and closing quotes
+			flush()
+			if i == 0 {
+				tokens = append(tokens, "``")
+			} else {
+				tokens = append(tokens, "''")
+			}
+		case strings.ContainsRune("?!;&()[]{}<>", r):
+			flush()
+			tokens = append(tokens, string(r))
+		case r == ',' || r == ':':
+			// separators within numbers are kept, like in "1,000"
+			if i > 0 && i < len(runes)-1 && unicode.IsDigit(runes[i-1]) && unicode.IsDigit(runes[i+1]) {
+				word.WriteRune(r)
+				continue
+			}
+			flush()
+			tokens = append(tokens, string(r))
+		default:
+			word.WriteRune(r)
+		}
+	}
+	flush()
+
+	// a final period is split, unless the word is an abbreviation like "U.S."
+	if n := len(tokens); n > 0 {
+		last := tokens[n-1]
+		if len(last) > 1 && strings.HasSuffix(last, ".") && strings.Count(last, ".") == 1 {
+			tokens = append(tokens[:n-1], last[:len(last)-1], ".")
+		}
+	}
+	return tokens
+}
+
+func splitContraction(word string) []string {
+	lower := strings.ToLower(word)
+	for _, suffix := range contractions {
+		if len(word) > len(suffix) && strings.HasSuffix(lower, suffix) {
+			return []string{word[:len(word)-len(suffix)], word[len(word)-len(suffix):]}
+		}
+	}
+	return []string{word}
+}
+
+// murmur3 returns the 32 bits MurmurHash3 of the data, with a seed of 0,
+// which is how the reference BM25 encoder of Pinecone turns tokens into
+// sparse indices.
+func murmur3(data []byte) uint32 {
+	const (
+		c1 = 0xcc9e2d51
+		c2 = 0x1b873593
+	)
+
+	var h uint32
+	n := len(data)
+	for i := 0; i+4 <= n; i += 4 {
+		k := binary.LittleEndian.Uint32(data[i:])
+		k *= c1
+		k = bits.RotateLeft32(k, 15)
+		k *= c2
+
+		h ^= k
+		h = bits.RotateLeft32(h, 13)
+		h = h*5 + 0xe6546b64
+	}
+
+	var k uint32
+	tail := data[n&^3:]
+	switch len(tail) {
+	case 3:
+		k ^= uint32(tail[2]) << 16
+		fallthrough
+	case 2:
+		k ^= uint32(tail[1]) << 8
+		fallthrough
+	case 1:
+		k ^= uint32(tail[0])
+		k *= c1
+		k = bits.RotateLeft32(k, 15)
+		k *= c2
+		h ^= k
+	}
+
+	h ^= uint32(n) //nolint:gosec // the length only needs its low bits
+	h ^= h >> 16
+	h *= 0x85ebca6b
+	h ^= h >> 13
+	h *= 0xc2b2ae35
+	h ^= h >> 16
+	return h
+}
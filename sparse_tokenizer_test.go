@@ -0,0 +1,97 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pinecone
+
+import (
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestStem(t *testing.T) {
+	// samples of the vocabulary of the Snowball English stemmer
+	words := map[string]string{
+		"consign": "consign", "consigned": "consign", "consignment": "consign",
+		"consistency": "consist", "consistently": "consist",
+		"knackered": "knacker", "knackeries": "knackeri", "knaves": "knave",
+		"kneaded": "knead", "knightly": "knight", "knives": "knive", "knocking": "knock",
+		"generously": "generous", "generation": "generat", "arsenal": "arsenal", "communism": "communism",
+		"running": "run", "hopping": "hop", "hoping": "hope", "hopeful": "hope",
+		"happiness": "happi", "relational": "relat", "conditional": "condit",
+		"caresses": "caress", "ponies": "poni", "ties": "tie", "cries": "cri",
+		"gas": "gas", "gaps": "gap", "kiwis": "kiwi", "cats": "cat",
+		"cry": "cri", "by": "by", "say": "say", "yelling": "yell",
+		"skies": "sky", "dying": "die", "succeeding": "succeed", "innings": "inning",
+		"n't": "n't", "'s": "'s",
+	}
+
+	for word, want := range words {
+		t.Run(word, func(t *testing.T) {
+			is := is.New(t)
+			is.Equal(stem(word), want)
+		})
+	}
+}
+
+func TestBM25Tokenizer(t *testing.T) {
+	tokenizer := bm25Tokenizer{lowerCase: true, removePunctuation: true, removeStopwords: true, stem: true}
+
+	testCases := []struct {
+		text string
+		want []string
+	}{
+		{text: "Hello, World! The runners don't stop.", want: []string{"hello", "world", "runner", "n't", "stop"}},
+		{text: "It costs 1,000 dollars (in the U.S.)", want: []string{"cost", "1,000", "dollar", "u.s."}},
+		{text: `He said "fine" ... twice`, want: []string{"said", "``", "fine", "''", "...", "twice"}},
+		{text: "the and of", want: nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.text, func(t *testing.T) {
+			is := is.New(t)
+			is.Equal(tokenizer.tokenize(tc.text), tc.want)
+		})
+	}
+
+	t.Run("options", func(t *testing.T) {
+		is := is.New(t)
+
+		raw := bm25Tokenizer{}
+		is.Equal(raw.tokenize("The runners, running."), []string{"The", "runners", ",", "running", "."})
+	})
+}
+
+func TestMurmur3(t *testing.T) {
+	testCases := []struct {
+		data string
+		want uint32
+	}{
+		{data: "", want: 0},
+		{data: "\xff\xff\xff\xff", want: 0x76293b50},
+		{data: "\x21\x43\x65\x87", want: 0xf55b516b},
+		{data: "\x21\x43\x65", want: 0x7e4a8634},
+		{data: "\x21\x43", want: 0xa0f7b07a},
+		{data: "\x21", want: 0x72661cf4},
+		// mmh3.hash("foo", signed=False)
+		{data: "foo", want: 4138058784},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.data, func(t *testing.T) {
+			is := is.New(t)
+			is.Equal(murmur3([]byte(tc.data)), tc.want)
+		})
+	}
+}
@@ -26,7 +26,10 @@ var version = "(devel)"
 func Specification() sdk.Specification {
 	return sdk.Specification{
 		Name:    "pinecone",
-		Summary: "A pinecone destination plugin for Conduit, written in Go.",
+		Summary: "A pinecone source and destination plugin for Conduit, written in Go.",
+		Description: "The source reads vectors from the namespaces of a Pinecone index, " +
+			"optionally detecting changes by polling. The destination upserts and deletes " +
+			"vectors in a namespace of a Pinecone index.",
 		Version: version,
 		Author:  "Meroxa, Inc.",
 	}
@@ -0,0 +1,142 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pinecone
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// TLSConfig contains the TLS settings used to connect to https hosts. They are
+// ignored for http hosts, which are connected to over plaintext.
+type TLSConfig struct {
+	// CACertFile is the path to a PEM encoded CA bundle used to verify the
+	// certificate of the host. Defaults to the system CA pool.
+	CACertFile string `json:"caCertFile"`
+
+	// InsecureSkipVerify disables the verification of the certificate of the
+	// host. It should only be used for testing.
+	InsecureSkipVerify bool `json:"insecureSkipVerify"`
+}
+
+// indexHost is a parsed Pinecone index host URL.
+type indexHost struct {
+	// target is the host and optional port to dial.
+	target string
+	// plaintext is true for http hosts, such as the Pinecone Local emulator.
+	plaintext bool
+}
+
+// parseIndexHost parses the host parameter. The scheme defaults to https when
+// the host has none, e.g. "index-abc.svc.pinecone.io".
+func parseIndexHost(host string) (indexHost, error) {
+	hostURL, err := url.Parse(host)
+	if err != nil || hostURL.Host == "" {
+		// no scheme, the host is taken as is
+		hostURL, err = url.Parse("https://" + host)
+		if err != nil {
+			return indexHost{}, fmt.Errorf("invalid host url: %w", err)
+		}
+	}
+
+	switch hostURL.Scheme {
+	case "https":
+		return indexHost{target: hostURL.Host}, nil
+	case "http":
+		return indexHost{target: hostURL.Host, plaintext: true}, nil
+	default:
+		return indexHost{}, fmt.Errorf("unsupported host url scheme %q, use http or https", hostURL.Scheme)
+	}
+}
+
+// url returns the host with its scheme, as expected by the Pinecone client.
+func (h indexHost) url() string {
+	if h.plaintext {
+		return "http://" + h.target
+	}
+	return "https://" + h.target
+}
+
+// validateConnection checks that the host can be parsed and that the TLS
+// settings can be loaded, so that misconfigurations fail on Configure.
+func validateConnection(host string, tlsConfig TLSConfig) error {
+	parsed, err := parseIndexHost(host)
+	if err != nil {
+		return err
+	}
+
+	_, err = tlsConfig.transportCredentials(parsed)
+	return err
+}
+
+// transportCredentials returns the gRPC credentials to connect to the host.
+func (c TLSConfig) transportCredentials(host indexHost) (credentials.TransportCredentials, error) {
+	if host.plaintext {
+		return insecure.NewCredentials(), nil
+	}
+
+	tlsConfig, err := c.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	return credentials.NewTLS(tlsConfig), nil
+}
+
+// httpClient returns the HTTP client to send requests to the host, for the
+// endpoints that the Pinecone client doesn't support.
+func (c TLSConfig) httpClient(host indexHost) (*http.Client, error) {
+	if host.plaintext {
+		return &http.Client{}, nil
+	}
+
+	tlsConfig, err := c.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone() //nolint:forcetypeassert // always a transport
+	transport.TLSClientConfig = tlsConfig
+	return &http.Client{Transport: transport}, nil
+}
+
+func (c TLSConfig) tlsConfig() (*tls.Config, error) {
+	//nolint:gosec // skipping verification is opt-in and documented as insecure
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: c.InsecureSkipVerify,
+	}
+
+	if c.CACertFile != "" {
+		pem, err := os.ReadFile(c.CACertFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA cert file: %w", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("CA cert file contains no valid PEM certificates")
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return tlsConfig, nil
+}
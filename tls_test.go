@@ -0,0 +1,107 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pinecone
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestParseIndexHost(t *testing.T) {
+	testCases := []struct {
+		host string
+		want indexHost
+	}{
+		{host: "https://index-abc.svc.pinecone.io", want: indexHost{target: "index-abc.svc.pinecone.io"}},
+		{host: "index-abc.svc.pinecone.io", want: indexHost{target: "index-abc.svc.pinecone.io"}},
+		{host: "http://localhost:5080", want: indexHost{target: "localhost:5080", plaintext: true}},
+		{host: "localhost:5080", want: indexHost{target: "localhost:5080"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.host, func(t *testing.T) {
+			is := is.New(t)
+
+			got, err := parseIndexHost(tc.host)
+			is.NoErr(err)
+			is.Equal(got, tc.want)
+		})
+	}
+
+	t.Run("unsupported scheme", func(t *testing.T) {
+		is := is.New(t)
+
+		_, err := parseIndexHost("ftp://localhost:5080")
+		is.True(err != nil)
+	})
+}
+
+func TestTLSConfig_TransportCredentials(t *testing.T) {
+	secure := indexHost{target: "index-abc.svc.pinecone.io"}
+
+	t.Run("plaintext", func(t *testing.T) {
+		is := is.New(t)
+
+		creds, err := TLSConfig{}.transportCredentials(indexHost{target: "localhost:5080", plaintext: true})
+		is.NoErr(err)
+		is.Equal(creds.Info().SecurityProtocol, "insecure")
+	})
+
+	t.Run("tls", func(t *testing.T) {
+		is := is.New(t)
+
+		creds, err := TLSConfig{InsecureSkipVerify: true}.transportCredentials(secure)
+		is.NoErr(err)
+		is.Equal(creds.Info().SecurityProtocol, "tls")
+	})
+
+	t.Run("missing CA cert file", func(t *testing.T) {
+		is := is.New(t)
+
+		cfg := TLSConfig{CACertFile: filepath.Join(t.TempDir(), "missing.pem")}
+		_, err := cfg.transportCredentials(secure)
+		is.True(err != nil)
+	})
+
+	t.Run("invalid CA cert file", func(t *testing.T) {
+		is := is.New(t)
+
+		path := filepath.Join(t.TempDir(), "ca.pem")
+		is.NoErr(os.WriteFile(path, []byte("not a certificate"), 0o600))
+
+		_, err := TLSConfig{CACertFile: path}.transportCredentials(secure)
+		is.True(err != nil)
+	})
+}
+
+func TestTLSConfig_HTTPClient(t *testing.T) {
+	is := is.New(t)
+
+	client, err := TLSConfig{}.httpClient(indexHost{target: "localhost:5080", plaintext: true})
+	is.NoErr(err)
+	is.Equal(client.Transport, nil)
+
+	client, err = TLSConfig{InsecureSkipVerify: true}.httpClient(indexHost{target: "index-abc.svc.pinecone.io"})
+	is.NoErr(err)
+	is.True(client.Transport.(*http.Transport).TLSClientConfig.InsecureSkipVerify)
+
+	cfg := TLSConfig{CACertFile: filepath.Join(t.TempDir(), "missing.pem")}
+	_, err = cfg.httpClient(indexHost{target: "index-abc.svc.pinecone.io"})
+	is.True(err != nil)
+}
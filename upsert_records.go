@@ -0,0 +1,182 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pinecone
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/pinecone-io/go-pinecone/pinecone"
+)
+
+const (
+	upsertModeVectors = "vectors"
+	upsertModeRecords = "records"
+)
+
+// recordsAPIVersion is the version of the Pinecone API that introduced the
+// upsert records endpoint.
+const recordsAPIVersion = "2025-01"
+
+// defaultRecordsNamespace is the name of the default namespace in the records
+// endpoints, which can't be empty.
+const defaultRecordsNamespace = "__default__"
+
+// RecordsConfig configures the upsert of text records into an index with
+// integrated embedding, which embeds them itself.
+type RecordsConfig struct {
+	// Field is the field holding the text of the record, as a dot separated
+	// path or a JSON pointer. It's read within each chunk if records are
+	// fanned out into chunks.
+	Field string `json:"field" default:"text"`
+
+	// IndexField is the field of the Pinecone records that the index embeds,
+	// as set in the field map of its embedding configuration.
+	IndexField string `json:"indexField" default:"chunk_text"`
+
+	// BatchSize is the maximum number of records upserted by a single
+	// request. Pinecone accepts up to 96 records per request.
+	BatchSize int `json:"batchSize" default:"96" validate:"gt=0"`
+
+	// Timeout is the timeout of a single upsert records request, which
+	// includes the embedding of the records. Requests timing out are retried
+	// like failed writes.
+	Timeout time.Duration `json:"timeout" default:"30s"`
+}
+
+// newRecordsText returns the source of the text of the records, nil if
+// vectors are upserted. The index embeds the records, so vectors can't be
+// embedded nor sparse encoded by the connector too.
+func newRecordsText(mode string, cfg RecordsConfig, embedding EmbeddingConfig, sparse SparseConfig) (*textSource, error) {
+	if mode != upsertModeRecords {
+		return nil, nil //nolint:nilnil // vectors are upserted
+	}
+	if embedding.enabled() {
+		return nil, errors.New("embedding.provider can't be used with the records upsert mode, the index embeds the records")
+	}
+	if sparse.enabled() {
+		return nil, errors.New("sparse.encoder can't be used with the records upsert mode, the index embeds the records")
+	}
+	if cfg.IndexField == "" || cfg.IndexField == "_id" || cfg.IndexField == "id" {
+		return nil, fmt.Errorf("invalid records.indexField %q, it's the field of the text and not of the ID", cfg.IndexField)
+	}
+
+	text, err := newTextSource(cfg.Field, "")
+	if err != nil {
+		return nil, fmt.Errorf("invalid records field: %w", err)
+	}
+	return text, nil
+}
+
+// recordsUpserter upserts text records into an index with integrated
+// embedding, with the upsert records endpoint that the Pinecone client
+// doesn't support.
+type recordsUpserter struct {
+	client     *http.Client
+	host       indexHost
+	apiKey     string
+	indexField string
+	limits     requestLimits
+	timeout    time.Duration
+}
+
+func newRecordsUpserter(apiKey, host string, tls TLSConfig, cfg RecordsConfig, maxBytes int) (*recordsUpserter, error) {
+	parsed, err := parseIndexHost(host)
+	if err != nil {
+		return nil, err
+	}
+	client, err := tls.httpClient(parsed)
+	if err != nil {
+		return nil, err
+	}
+
+	return &recordsUpserter{
+		client:     client,
+		host:       parsed,
+		apiKey:     apiKey,
+		indexField: cfg.IndexField,
+		limits:     requestLimits{maxVectors: cfg.BatchSize, maxBytes: maxBytes},
+		timeout:    cfg.Timeout,
+	}, nil
+}
+
+// textRecord is a record of the upsert records request, already encoded as a
+// line of newline delimited JSON.
+type textRecord struct {
+	id   string
+	line []byte
+}
+
+func (r textRecord) size() int {
+	return len(r.line) + 1
+}
+
+// record builds the record of the vector: its ID, its text and the fields of
+// its metadata. The ID and the text take precedence over metadata fields
+// with the same name.
+func (u *recordsUpserter) record(vec *pinecone.Vector, text string) (textRecord, error) {
+	fields := make(map[string]any)
+	if vec.Metadata != nil {
+		fields = vec.Metadata.AsMap()
+	}
+	fields["_id"] = vec.Id
+	fields[u.indexField] = text
+
+	line, err := json.Marshal(fields)
+	if err != nil {
+		return textRecord{}, fmt.Errorf("failed to encode record %q: %w", vec.Id, err)
+	}
+	return textRecord{id: vec.Id, line: line}, nil
+}
+
+// upsert sends the records to the namespace in a single request.
+func (u *recordsUpserter) upsert(ctx context.Context, namespace string, records []textRecord) error {
+	if u.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, u.timeout)
+		defer cancel()
+	}
+
+	if namespace == "" {
+		namespace = defaultRecordsNamespace
+	}
+	endpoint := u.host.url() + "/records/namespaces/" + url.PathEscape(namespace) + "/upsert"
+
+	var body bytes.Buffer
+	for _, rec := range records {
+		body.Write(rec.line)
+		body.WriteByte('\n')
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, &body)
+	if err != nil {
+		return fmt.Errorf("failed to create upsert records request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	req.Header.Set("Api-Key", u.apiKey)
+	req.Header.Set("X-Pinecone-API-Version", recordsAPIVersion)
+
+	resp, err := doHTTP(u.client, req, "upsert records")
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
@@ -0,0 +1,309 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pinecone
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/conduitio/conduit-commons/opencdc"
+	"github.com/matryer/is"
+)
+
+// fakeRecordsServer is a local stand-in of the upsert records endpoint of an
+// index with integrated embedding.
+type fakeRecordsServer struct {
+	*httptest.Server
+
+	m sync.Mutex
+	// requests are the decoded records of each request, including the
+	// failed ones.
+	requests [][]map[string]any
+	// paths are the paths of the requests.
+	paths []string
+	// headers are the headers of the last request.
+	headers http.Header
+	// failures are the status codes returned by the next requests, instead of
+	// handling them. A zero status code lets its request through.
+	failures []int
+}
+
+func newFakeRecordsServer(t *testing.T) *fakeRecordsServer {
+	s := &fakeRecordsServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *fakeRecordsServer) handle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.NotFound(w, r)
+		return
+	}
+
+	var records []map[string]any
+	scanner := bufio.NewScanner(r.Body)
+	for scanner.Scan() {
+		var rec map[string]any
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		records = append(records, rec)
+	}
+
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	s.requests = append(s.requests, records)
+	s.paths = append(s.paths, r.URL.Path)
+	s.headers = r.Header.Clone()
+	if len(s.failures) > 0 {
+		failure := s.failures[0]
+		s.failures = s.failures[1:]
+		if failure != 0 {
+			http.Error(w, "fake failure", failure)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+func recordsWriterOptions(is *is.I, server *fakeRecordsServer, fields FieldsConfig) writerOptions {
+	opts, err := recordsDestConfig(server, fields).writerOptions(&indexDescription{dimension: 2, vectorType: vectorTypeDense})
+	is.NoErr(err)
+	return opts
+}
+
+func recordsDestConfig(server *fakeRecordsServer, fields FieldsConfig) DestinationConfig {
+	return DestinationConfig{
+		APIKey:          "secret",
+		Host:            server.URL,
+		MaxRequestBytes: 2 << 20,
+		UpsertMode:      upsertModeRecords,
+		Retry:           RetryConfig{MaxRetries: 2, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond},
+		Fields:          fields,
+		Metadata:        MetadataConfig{PayloadFields: []string{"category"}},
+		Records:         RecordsConfig{Field: "text", IndexField: "chunk_text", BatchSize: 2, Timeout: time.Second},
+	}
+}
+
+func TestUpsertBatch_Records(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	server := newFakeRecordsServer(t)
+	// the first request is retried
+	server.failures = []int{http.StatusServiceUnavailable}
+
+	colWriter := singleCollectionWriter{opts: recordsWriterOptions(is, server, defaultFieldsConfig)}
+	batches, err := colWriter.buildBatches(ctx, []opencdc.Record{
+		{
+			Operation: opencdc.OperationCreate,
+			Key:       opencdc.RawData("doc1"),
+			Payload:   opencdc.Change{After: opencdc.RawData(`{"text": "first", "category": "a"}`)},
+		},
+		{
+			Operation: opencdc.OperationCreate,
+			Key:       opencdc.RawData("doc2"),
+			// the values are ignored, the index embeds the text
+			Payload: opencdc.Change{After: opencdc.RawData(`{"text": "second", "values": [1, 2]}`)},
+		},
+		{
+			Operation: opencdc.OperationUpdate,
+			Key:       opencdc.RawData("doc3"),
+			Payload:   opencdc.Change{After: opencdc.RawData(`{"text": "third", "category": "c"}`)},
+		},
+		{
+			Operation: opencdc.OperationDelete,
+			Key:       opencdc.RawData("doc1"),
+		},
+	})
+	is.NoErr(err)
+	is.Equal(len(batches), 2)
+
+	// delete records still delete vectors by ID
+	_, ok := batches[1].(*deleteBatch)
+	is.True(ok)
+
+	written, err := batches[0].writeBatch(ctx, nil)
+	is.NoErr(err)
+	is.Equal(written, 3)
+
+	// the batch is split in requests of at most 2 records
+	is.Equal(len(server.requests), 3)
+	is.Equal(server.requests[1], []map[string]any{
+		{"_id": "doc1", "chunk_text": "first", "category": "a"},
+		{"_id": "doc2", "chunk_text": "second"},
+	})
+	is.Equal(server.requests[2], []map[string]any{
+		{"_id": "doc3", "chunk_text": "third", "category": "c"},
+	})
+	is.Equal(server.paths[0], "/records/namespaces/__default__/upsert")
+	is.Equal(server.headers.Get("Api-Key"), "secret")
+	is.Equal(server.headers.Get("Content-Type"), "application/x-ndjson")
+	is.Equal(server.headers.Get("X-Pinecone-API-Version"), recordsAPIVersion)
+
+	t.Run("chunks", func(t *testing.T) {
+		is := is.New(t)
+		server := newFakeRecordsServer(t)
+
+		fields := defaultFieldsConfig
+		fields.Chunks = "chunks"
+		colWriter := singleCollectionWriter{opts: recordsWriterOptions(is, server, fields)}
+		batches, err := colWriter.buildBatches(ctx, []opencdc.Record{{
+			Operation: opencdc.OperationCreate,
+			Key:       opencdc.RawData("doc1"),
+			Payload:   opencdc.Change{After: opencdc.RawData(`{"chunks": [{"text": "one"}, {"text": "two"}]}`)},
+		}})
+		is.NoErr(err)
+
+		batch := batches[0].(*upsertBatch)
+		batch.namespace = "docs"
+		written, err := batch.writeBatch(ctx, nil)
+		is.NoErr(err)
+		is.Equal(written, 1)
+		is.Equal(server.requests, [][]map[string]any{{
+			{"_id": "doc1#0", "chunk_text": "one"},
+			{"_id": "doc1#1", "chunk_text": "two"},
+		}})
+		is.Equal(server.paths[0], "/records/namespaces/docs/upsert")
+	})
+
+	t.Run("failed request", func(t *testing.T) {
+		is := is.New(t)
+		server := newFakeRecordsServer(t)
+		// the rate limited request is retried, unlike the bad one
+		server.failures = []int{http.StatusTooManyRequests, 0, http.StatusBadRequest}
+
+		colWriter := singleCollectionWriter{opts: recordsWriterOptions(is, server, defaultFieldsConfig)}
+		batches, err := colWriter.buildBatches(ctx, nTextRecords(3))
+		is.NoErr(err)
+
+		written, err := batches[0].writeBatch(ctx, nil)
+		is.True(err != nil)
+		is.Equal(written, 2)
+		is.Equal(len(server.requests), 3)
+	})
+
+	t.Run("rate limited", func(t *testing.T) {
+		is := is.New(t)
+		server := newFakeRecordsServer(t)
+
+		// requests of records can hold more vectors than vector requests
+		cfg := recordsDestConfig(server, defaultFieldsConfig)
+		cfg.MaxRequestVectors = 1
+		cfg.RateLimit = RateLimitConfig{VectorsPerSecond: 1}
+		opts, err := cfg.writerOptions(&indexDescription{dimension: 2, vectorType: vectorTypeDense})
+		is.NoErr(err)
+
+		colWriter := singleCollectionWriter{opts: opts}
+		batches, err := colWriter.buildBatches(ctx, nTextRecords(2))
+		is.NoErr(err)
+
+		written, err := batches[0].writeBatch(ctx, nil)
+		is.NoErr(err)
+		is.Equal(written, 2)
+		is.Equal(len(server.requests), 1)
+	})
+
+	t.Run("missing text", func(t *testing.T) {
+		is := is.New(t)
+
+		_, err := colWriter.buildBatches(ctx, []opencdc.Record{{
+			Operation: opencdc.OperationCreate,
+			Key:       opencdc.RawData("doc1"),
+			Payload:   opencdc.Change{After: opencdc.RawData(`{"values": [1, 2]}`)},
+		}})
+		is.True(err != nil)
+	})
+}
+
+func TestDestination_OpenRecords(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	server := newFakeRecordsServer(t)
+
+	// the index isn't described, a sparse index would need indexName for it
+	records := recordsDestConfig(server, defaultFieldsConfig)
+	cfg := destConfigFromEnv(t)
+	cfg.Host = records.Host
+	cfg.UpsertMode = records.UpsertMode
+	cfg.Records = records.Records
+	cfg.IndexName = ""
+
+	dest := NewDestination()
+	is.NoErr(dest.Configure(ctx, cfg.toMap()))
+	is.NoErr(dest.Open(ctx))
+	defer teardown(ctx, is, dest)
+
+	written, err := dest.Write(ctx, nTextRecords(3))
+	is.NoErr(err)
+	is.Equal(written, 3)
+	is.Equal(len(server.requests), 2)
+}
+
+func nTextRecords(n int) []opencdc.Record {
+	recs := make([]opencdc.Record, n)
+	for i := range recs {
+		recs[i] = opencdc.Record{
+			Operation: opencdc.OperationCreate,
+			Key:       opencdc.RawData("doc" + string(rune('a'+i))),
+			Payload:   opencdc.Change{After: opencdc.RawData(`{"text": "some text"}`)},
+		}
+	}
+	return recs
+}
+
+func TestNewRecordsText(t *testing.T) {
+	is := is.New(t)
+
+	cfg := RecordsConfig{Field: "doc.text", IndexField: "chunk_text"}
+	none := EmbeddingConfig{Provider: embeddingProviderNone}
+	noSparse := SparseConfig{Encoder: sparseEncoderNone}
+
+	text, err := newRecordsText(upsertModeVectors, cfg, none, noSparse)
+	is.NoErr(err)
+	is.Equal(text, nil)
+
+	text, err = newRecordsText(upsertModeRecords, cfg, none, noSparse)
+	is.NoErr(err)
+	is.Equal(text.field.String(), "doc.text")
+
+	testCases := []struct {
+		name      string
+		cfg       RecordsConfig
+		embedding EmbeddingConfig
+		sparse    SparseConfig
+	}{
+		{name: "embedding", cfg: cfg, embedding: EmbeddingConfig{Provider: embeddingProviderLocal}, sparse: noSparse},
+		{name: "sparse encoding", cfg: cfg, embedding: none, sparse: SparseConfig{Encoder: sparseEncoderBM25}},
+		{name: "id index field", cfg: RecordsConfig{Field: "text", IndexField: "_id"}, embedding: none, sparse: noSparse},
+		{name: "empty index field", cfg: RecordsConfig{Field: "text"}, embedding: none, sparse: noSparse},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			is := is.New(t)
+			_, err := newRecordsText(upsertModeRecords, tc.cfg, tc.embedding, tc.sparse)
+			is.True(err != nil)
+		})
+	}
+}
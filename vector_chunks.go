@@ -0,0 +1,161 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pinecone
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/conduitio/conduit-commons/opencdc"
+	"github.com/pinecone-io/go-pinecone/pinecone"
+)
+
+// maxChunkPositionBytes is the room left for the position of the chunks in
+// their generated IDs.
+const maxChunkPositionBytes = 10
+
+// chunksParser locates the chunks that records are fanned out into.
+type chunksParser struct {
+	field fieldPath
+	// idField is nil if the chunk IDs are generated out of their position.
+	idField   fieldPath
+	separator string
+}
+
+// newChunksParser returns nil if records aren't fanned out into chunks.
+func newChunksParser(cfg FieldsConfig) (*chunksParser, error) {
+	if cfg.Chunks == "" {
+		return nil, nil //nolint:nilnil // records are written as a single vector
+	}
+
+	var p chunksParser
+	var err error
+	if p.field, err = parseFieldPath(cfg.Chunks); err != nil {
+		return nil, fmt.Errorf("invalid chunks field: %w", err)
+	}
+	if cfg.ChunkID != "" {
+		if p.idField, err = parseFieldPath(cfg.ChunkID); err != nil {
+			return nil, fmt.Errorf("invalid chunk ID field: %w", err)
+		}
+	}
+	p.separator = cfg.ChunkSeparator
+	if p.idField == nil && p.separator == "" {
+		return nil, errors.New("chunkSeparator is required by the generated chunk IDs")
+	}
+
+	return &p, nil
+}
+
+// staleChunks locates the chunks left over by an update record with fewer
+// chunks than before: the ones with a generated ID starting with prefix, and
+// a position not lower than count.
+type staleChunks struct {
+	prefix string
+	count  int
+}
+
+func (s staleChunks) isStale(id string) bool {
+	pos, err := strconv.Atoi(strings.TrimPrefix(id, s.prefix))
+	return err == nil && pos >= s.count
+}
+
+// parseChunks parses the record into one vector per chunk, along with the
+// texts to embed into their dense values, empty for the vectors that have
+// them, like parseUpsert. For update records with generated chunk IDs, it
+// also returns the stale chunks to delete once the vectors are upserted.
+func (p *vectorParser) parseChunks(
+	ctx context.Context, rec opencdc.Record,
+) ([]*pinecone.Vector, []string, *staleChunks, error) {
+	payload, err := unmarshalPayload(rec.Payload.After)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	value, ok := p.chunks.field.get(payload)
+	if !ok {
+		return nil, nil, nil, fmt.Errorf("record has no chunks field %q", p.chunks.field)
+	}
+	chunks, ok := value.([]any)
+	if !ok {
+		return nil, nil, nil, fmt.Errorf("field %q is a %T, expected an array of chunks", p.chunks.field, value)
+	}
+
+	prefix, originalPrefix, err := p.vectorIDPrefix(rec, p.chunks.separator)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	vectors := make([]*pinecone.Vector, len(chunks))
+	texts := make([]string, len(chunks))
+	for i, item := range chunks {
+		vec, text, err := p.parseChunk(ctx, rec, payload, item, prefix, originalPrefix, i)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("chunk %d: %w", i, err)
+		}
+		vectors[i], texts[i] = vec, text
+	}
+
+	var stale *staleChunks
+	if rec.Operation == opencdc.OperationUpdate && p.chunks.idField == nil {
+		stale = &staleChunks{
+			prefix: prefix,
+			count:  len(chunks),
+		}
+	}
+
+	return vectors, texts, stale, nil
+}
+
+// parseChunk parses the chunk at the position into a vector. Generated chunk
+// IDs are the position after the prefix, which was already replaced if the ID
+// could be invalid, while chunk IDs read from the chunk are replaced here.
+func (p *vectorParser) parseChunk(
+	ctx context.Context, rec opencdc.Record,
+	payload, item any, prefix, originalPrefix string, pos int,
+) (*pinecone.Vector, string, error) {
+	chunk, ok := item.(map[string]any)
+	if !ok {
+		return nil, "", fmt.Errorf("chunk is a %T, expected an object", item)
+	}
+
+	id := prefix + strconv.Itoa(pos)
+	var originalID string
+	if originalPrefix != "" {
+		originalID = originalPrefix + strconv.Itoa(pos)
+	}
+	if p.chunks.idField != nil {
+		value, ok := p.chunks.idField.get(chunk)
+		if !ok {
+			return nil, "", fmt.Errorf("chunk has no ID field %q", p.chunks.idField)
+		}
+		if id, ok = value.(string); !ok || id == "" {
+			return nil, "", fmt.Errorf("chunk ID field %q is %v, expected a non-empty string", p.chunks.idField, value)
+		}
+
+		var err error
+		if id, originalID, err = p.idPolicy.apply(id); err != nil {
+			return nil, "", err
+		}
+	}
+
+	vec, err := p.buildVector(ctx, rec, payload, chunk, id, originalID)
+	if err != nil {
+		return nil, "", err
+	}
+	return p.prepare(rec, vec, chunk)
+}
@@ -0,0 +1,206 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pinecone
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/conduitio/conduit-commons/opencdc"
+	"github.com/matryer/is"
+)
+
+func TestVectorParser_Chunks(t *testing.T) {
+	testCases := []struct {
+		name      string
+		chunkID   string
+		operation opencdc.Operation
+		payload   string
+		wantIDs   []string
+		wantMeta  []map[string]any
+		wantStale *staleChunks
+		wantErr   bool
+	}{
+		{
+			name:      "generated ids",
+			operation: opencdc.OperationCreate,
+			payload: `{"title": "doc", "chunks": [
+				{"values": [1, 2], "metadata": {"text": "a"}},
+				{"values": [3, 4], "metadata": {"text": "b"}}
+			]}`,
+			wantIDs: []string{"doc1#0", "doc1#1"},
+			wantMeta: []map[string]any{
+				{"title": "doc", "text": "a"},
+				{"title": "doc", "text": "b"},
+			},
+		},
+		{
+			name:      "update deletes stale chunks",
+			operation: opencdc.OperationUpdate,
+			payload:   `{"chunks": [{"values": [1, 2]}]}`,
+			wantIDs:   []string{"doc1#0"},
+			wantMeta:  []map[string]any{{}},
+			wantStale: &staleChunks{prefix: "doc1#", count: 1},
+		},
+		{
+			name:      "chunk id field",
+			chunkID:   "id",
+			operation: opencdc.OperationUpdate,
+			payload:   `{"chunks": [{"id": "a", "values": [1, 2]}, {"id": "b", "values": [3, 4]}]}`,
+			wantIDs:   []string{"a", "b"},
+			wantMeta:  []map[string]any{{}, {}},
+		},
+		{
+			name:      "no chunks",
+			operation: opencdc.OperationCreate,
+			payload:   `{"chunks": []}`,
+			wantIDs:   []string{},
+		},
+		{name: "missing chunks field", payload: `{"values": [1, 2]}`, wantErr: true},
+		{name: "chunks not an array", payload: `{"chunks": {"values": [1, 2]}}`, wantErr: true},
+		{name: "chunk not an object", payload: `{"chunks": [[1, 2]]}`, wantErr: true},
+		{name: "chunk without values", payload: `{"chunks": [{"values": []}]}`, wantErr: true},
+		{name: "missing chunk id", chunkID: "id", payload: `{"chunks": [{"values": [1, 2]}]}`, wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			is := is.New(t)
+			ctx := context.Background()
+
+			cfg := defaultFieldsConfig
+			cfg.Metadata = "metadata"
+			cfg.Chunks = "chunks"
+			cfg.ChunkID = tc.chunkID
+			parser, err := newVectorParser(cfg, MetadataConfig{PayloadFields: []string{"*"}})
+			is.NoErr(err)
+
+			vectors, _, stale, err := parser.parseChunks(ctx, opencdc.Record{
+				Operation: tc.operation,
+				Key:       opencdc.RawData("doc1"),
+				Payload:   opencdc.Change{After: opencdc.RawData(tc.payload)},
+			})
+			if tc.wantErr {
+				is.True(err != nil)
+				return
+			}
+			is.NoErr(err)
+			is.Equal(stale, tc.wantStale)
+
+			ids := make([]string, len(vectors))
+			for i, vec := range vectors {
+				ids[i] = vec.Id
+				is.Equal(vec.Metadata.AsMap(), tc.wantMeta[i])
+			}
+			is.Equal(ids, tc.wantIDs)
+		})
+	}
+}
+
+func TestStaleChunks(t *testing.T) {
+	is := is.New(t)
+
+	stale := staleChunks{prefix: "doc1#", count: 2}
+	is.True(!stale.isStale("doc1#0"))
+	is.True(!stale.isStale("doc1#1"))
+	is.True(stale.isStale("doc1#2"))
+	is.True(stale.isStale("doc1#10"))
+	// not generated chunk IDs
+	is.True(!stale.isStale("doc1#summary"))
+}
+
+func TestVectorParser_ChunksInvalidID(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+
+	cfg := defaultFieldsConfig
+	cfg.Chunks = "chunks"
+	parser, err := newVectorParser(cfg, MetadataConfig{})
+	is.NoErr(err)
+	parser.idPolicy, err = newVectorIDPolicy(InvalidIDConfig{
+		Policy:           invalidIDPolicySHA256,
+		OriginalKeyField: "original_key",
+	})
+	is.NoErr(err)
+
+	// valid on its own, but not once followed by the chunk positions
+	longKey := strings.Repeat("k", maxVectorIDBytes-2)
+	opts := writerOptions{parser: parser, deleteByPrefix: true, deletePrefixSeparator: "#"}
+	colWriter := singleCollectionWriter{opts: opts}
+
+	batches, err := colWriter.buildBatches(ctx, []opencdc.Record{
+		{
+			Operation: opencdc.OperationUpdate,
+			Key:       opencdc.RawData(longKey),
+			Payload:   opencdc.Change{After: opencdc.RawData(`{"chunks": [{"values": [1, 2]}, {"values": [3, 4]}]}`)},
+		},
+		{Operation: opencdc.OperationDelete, Key: opencdc.RawData(longKey)},
+	})
+	is.NoErr(err)
+	is.Equal(len(batches), 2)
+
+	// only the record part of the chunk IDs is hashed
+	batch := batches[0].(*upsertBatch)
+	prefix := batch.vectors[0].Id[:len(batch.vectors[0].Id)-1]
+	is.Equal(len(prefix), 64+1)
+	is.Equal(batch.vectors[0].Id, prefix+"0")
+	is.Equal(batch.vectors[1].Id, prefix+"1")
+	is.Equal(batch.vectors[1].Metadata.AsMap()["original_key"], longKey+"#1")
+
+	// the stale chunks and the prefix delete match the chunk IDs
+	is.Equal(batch.staleChunks, &staleChunks{prefix: prefix, count: 2})
+	is.True(batch.staleChunks.isStale(prefix + "2"))
+	is.Equal(batches[1].(*prefixDeleteBatch).prefixes, []string{prefix})
+}
+
+func TestUpsertBatch_Chunks(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+
+	cfg := defaultFieldsConfig
+	cfg.Chunks = "chunks"
+	parser, err := newVectorParser(cfg, MetadataConfig{})
+	is.NoErr(err)
+
+	colWriter := singleCollectionWriter{opts: writerOptions{parser: parser}}
+	record := func(op opencdc.Operation, key string) opencdc.Record {
+		return opencdc.Record{
+			Operation: op,
+			Key:       opencdc.RawData(key),
+			Payload:   opencdc.Change{After: opencdc.RawData(`{"chunks": [{"values": [1, 2]}, {"values": [3, 4]}]}`)},
+		}
+	}
+
+	batches, err := colWriter.buildBatches(ctx, []opencdc.Record{
+		record(opencdc.OperationCreate, "doc1"),
+		record(opencdc.OperationUpdate, "doc2"),
+		record(opencdc.OperationCreate, "doc3"),
+	})
+	is.NoErr(err)
+	// the stale chunks of the update close the first batch
+	is.Equal(len(batches), 2)
+
+	first := batches[0].(*upsertBatch)
+	is.Equal(len(first.vectors), 4)
+	is.Equal(first.recordEnds, []int{2, 4})
+	is.Equal(first.staleChunks, &staleChunks{prefix: "doc2#", count: 2})
+	is.Equal(first.writtenRecords(3), 1)
+	is.Equal(first.writtenRecords(4), 2)
+
+	second := batches[1].(*upsertBatch)
+	is.Equal(second.vectors[0].Id, "doc3#0")
+	is.Equal(second.staleChunks, nil)
+}
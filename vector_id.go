@@ -0,0 +1,232 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pinecone
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/conduitio/conduit-commons/opencdc"
+	"github.com/google/uuid"
+)
+
+// vectorIDFuncs are the helper functions available in the id template.
+var vectorIDFuncs = template.FuncMap{
+	// string converts the value to a string, e.g. {{ string .Key }}, as raw
+	// data is otherwise printed as a list of bytes.
+	"string": templateString,
+	// join joins the values with the separator, e.g.
+	// {{ join "#" .Key.tenant .Key.id }}.
+	"join": func(sep string, values ...any) string {
+		strs := make([]string, len(values))
+		for i, v := range values {
+			strs[i] = templateString(v)
+		}
+		return strings.Join(strs, sep)
+	},
+	// prefix prepends the prefix to the value, e.g. {{ prefix "doc-" .Key }}.
+	"prefix": func(prefix string, value any) string {
+		return prefix + templateString(value)
+	},
+	// sha256 returns the hex encoded SHA-256 hash of the value, e.g.
+	// {{ sha256 .Key }}.
+	"sha256": func(value any) string {
+		sum := sha256.Sum256([]byte(templateString(value)))
+		return hex.EncodeToString(sum[:])
+	},
+}
+
+// templateString converts template values to strings, printing raw data as
+// text and structured data as JSON.
+func templateString(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case opencdc.Data:
+		return string(v.Bytes())
+	case []byte:
+		return string(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// vectorIDTemplate builds the IDs of the vectors out of the records.
+type vectorIDTemplate struct {
+	template *template.Template
+}
+
+// newVectorIDTemplate parses the id template. It returns nil if the template
+// is empty, in which case the record key is the vector ID.
+func newVectorIDTemplate(tmpl string) (*vectorIDTemplate, error) {
+	if tmpl == "" {
+		return nil, nil //nolint:nilnil // the record key is used
+	}
+
+	t, err := template.New("id").Funcs(vectorIDFuncs).Option("missingkey=error").Parse(tmpl)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse id template %s: %w", tmpl, err)
+	}
+	return &vectorIDTemplate{template: t}, nil
+}
+
+// id returns the ID of the vector of the record, used both by upserts and
+// deletes.
+func (t *vectorIDTemplate) id(rec opencdc.Record) (string, error) {
+	if t == nil {
+		// Pinecone rejects the whole request of an empty ID
+		id := vectorID(rec.Key)
+		if id == "" {
+			return "", errors.New("record has no key to use as the vector ID")
+		}
+		return id, nil
+	}
+
+	var sb strings.Builder
+	if err := t.template.Execute(&sb, rec); err != nil {
+		return "", fmt.Errorf("failed to execute id template: %w", err)
+	}
+	if sb.Len() == 0 {
+		return "", errors.New("id template produced an empty vector ID")
+	}
+	return sb.String(), nil
+}
+
+// maxVectorIDBytes is the maximum length of a Pinecone vector ID.
+const maxVectorIDBytes = 512
+
+const (
+	invalidIDPolicyReject = "reject"
+	invalidIDPolicySHA256 = "sha256"
+	invalidIDPolicyUUID5  = "uuid5"
+)
+
+// InvalidIDConfig configures how the vector IDs that Pinecone would reject,
+// because they're longer than 512 bytes or contain control characters or
+// invalid UTF-8, are handled.
+type InvalidIDConfig struct {
+	// Policy is one of "reject", failing the record, "sha256", replacing the
+	// ID with its hex encoded SHA-256 hash, or "uuid5", replacing the ID with
+	// a UUIDv5 within uuidNamespace.
+	Policy string `json:"policy" default:"reject" validate:"inclusion=reject|sha256|uuid5"`
+
+	// UUIDNamespace is the namespace of the UUIDv5 IDs.
+	UUIDNamespace string `json:"uuidNamespace" default:"6ba7b811-9dad-11d1-80b4-00c04fd430c8"`
+
+	// OriginalKeyField is the metadata key holding the original ID of the
+	// vectors whose ID is replaced, so that it's still searchable. The
+	// original ID isn't stored if empty.
+	OriginalKeyField string `json:"originalKeyField"`
+}
+
+// vectorIDPolicy handles the vector IDs that Pinecone would reject.
+type vectorIDPolicy struct {
+	policy           string
+	uuidNamespace    uuid.UUID
+	originalKeyField string
+}
+
+func newVectorIDPolicy(cfg InvalidIDConfig) (*vectorIDPolicy, error) {
+	p := &vectorIDPolicy{
+		policy:           cfg.Policy,
+		originalKeyField: cfg.OriginalKeyField,
+	}
+	if p.policy == "" {
+		p.policy = invalidIDPolicyReject
+	}
+
+	if p.policy == invalidIDPolicyUUID5 {
+		ns, err := uuid.Parse(cfg.UUIDNamespace)
+		if err != nil {
+			return nil, fmt.Errorf("invalid uuid namespace %q: %w", cfg.UUIDNamespace, err)
+		}
+		p.uuidNamespace = ns
+	}
+
+	return p, nil
+}
+
+// apply returns the ID to write, replaced if it's invalid and the policy
+// allows it. The original ID is returned too if it was replaced.
+func (p *vectorIDPolicy) apply(id string) (string, string, error) {
+	return p.replace(id, invalidVectorID(id))
+}
+
+// applyPrefix is like apply for an ID that gets a suffix of up to suffixBytes,
+// like the position of a chunk. The ID is also replaced if it would be too
+// long with the suffix, so that the suffix is kept as is.
+func (p *vectorIDPolicy) applyPrefix(id string, suffixBytes int) (string, string, error) {
+	problem := invalidVectorID(id)
+	if problem == "" && len(id)+suffixBytes > maxVectorIDBytes {
+		problem = fmt.Sprintf("%d bytes followed by up to %d bytes is longer than the maximum of %d bytes",
+			len(id), suffixBytes, maxVectorIDBytes)
+	}
+	return p.replace(id, problem)
+}
+
+// replace replaces the ID if there's a problem with it and the policy allows
+// it.
+func (p *vectorIDPolicy) replace(id, problem string) (string, string, error) {
+	if problem == "" {
+		return id, "", nil
+	}
+
+	policy := invalidIDPolicyReject
+	if p != nil {
+		policy = p.policy
+	}
+
+	switch policy {
+	case invalidIDPolicySHA256:
+		sum := sha256.Sum256([]byte(id))
+		return hex.EncodeToString(sum[:]), id, nil
+	case invalidIDPolicyUUID5:
+		return uuid.NewSHA1(p.uuidNamespace, []byte(id)).String(), id, nil
+	default:
+		return "", "", fmt.Errorf("invalid vector ID %q: %s", truncateID(id), problem)
+	}
+}
+
+// invalidVectorID returns why Pinecone would reject the ID, or an empty string
+// if the ID is valid.
+func invalidVectorID(id string) string {
+	if len(id) > maxVectorIDBytes {
+		return fmt.Sprintf("%d bytes is longer than the maximum of %d bytes", len(id), maxVectorIDBytes)
+	}
+	if !utf8.ValidString(id) {
+		return "not valid UTF-8"
+	}
+	for _, r := range id {
+		if unicode.IsControl(r) {
+			return fmt.Sprintf("contains the control character %U", r)
+		}
+	}
+	return ""
+}
+
+// truncateID shortens the ID in error messages.
+func truncateID(id string) string {
+	const maxLen = 64
+	if len(id) <= maxLen {
+		return id
+	}
+	return id[:maxLen] + "..."
+}
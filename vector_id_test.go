@@ -0,0 +1,207 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pinecone
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/conduitio/conduit-commons/opencdc"
+	"github.com/google/uuid"
+	"github.com/matryer/is"
+)
+
+func TestVectorIDTemplate(t *testing.T) {
+	structuredKey := opencdc.StructuredData{"tenant": "acme", "id": float64(42)}
+
+	testCases := []struct {
+		name     string
+		template string
+		key      opencdc.Data
+		want     string
+		wantErr  bool
+	}{
+		{name: "record key", key: opencdc.RawData("key1"), want: "key1"},
+		{name: "structured record key", key: structuredKey, want: `{"id":42,"tenant":"acme"}`},
+		{name: "string", template: `{{ string .Key }}`, key: opencdc.RawData("key1"), want: "key1"},
+		{name: "join", template: `{{ join "#" .Key.tenant .Key.id }}`, key: structuredKey, want: "acme#42"},
+		{name: "prefix", template: `{{ prefix "doc-" .Key }}`, key: opencdc.RawData("key1"), want: "doc-key1"},
+		{
+			name:     "sha256",
+			template: `{{ sha256 .Key }}`,
+			key:      opencdc.RawData("key1"),
+			want:     "8174099687a26621f4e2cdd7cc03b3dacedb3fb962255b1aafd033cabe831530",
+		},
+		{
+			name:     "metadata",
+			template: `{{ index .Metadata "opencdc.collection" }}-{{ string .Key }}`,
+			key:      opencdc.RawData("key1"),
+			want:     "ns-key1",
+		},
+		{name: "missing key field", template: `{{ .Key.missing }}`, key: structuredKey, wantErr: true},
+		{name: "empty id", template: `{{ "" }}`, key: opencdc.RawData("key1"), wantErr: true},
+		{name: "empty record key", key: opencdc.RawData(""), wantErr: true},
+		{name: "missing record key", wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			is := is.New(t)
+
+			ids, err := newVectorIDTemplate(tc.template)
+			is.NoErr(err)
+
+			got, err := ids.id(opencdc.Record{
+				Key:      tc.key,
+				Metadata: opencdc.Metadata{"opencdc.collection": "ns"},
+			})
+			if tc.wantErr {
+				is.True(err != nil)
+				return
+			}
+			is.NoErr(err)
+			is.Equal(got, tc.want)
+		})
+	}
+
+	t.Run("invalid template", func(t *testing.T) {
+		is := is.New(t)
+
+		_, err := newVectorIDTemplate(`{{ .Key`)
+		is.True(err != nil)
+	})
+}
+
+func TestVectorIDTemplate_UpsertsAndDeletes(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+
+	parser, err := newVectorParser(defaultFieldsConfig, MetadataConfig{})
+	is.NoErr(err)
+	parser.ids, err = newVectorIDTemplate(`{{ join "/" .Key.tenant .Key.id }}`)
+	is.NoErr(err)
+
+	colWriter := singleCollectionWriter{opts: writerOptions{parser: parser}}
+	key := opencdc.StructuredData{"tenant": "acme", "id": "doc1"}
+
+	batches, err := colWriter.buildBatches(ctx, []opencdc.Record{
+		{
+			Operation: opencdc.OperationCreate,
+			Key:       key,
+			Payload:   opencdc.Change{After: opencdc.RawData(`{"values": [1, 2]}`)},
+		},
+		{Operation: opencdc.OperationDelete, Key: key},
+	})
+	is.NoErr(err)
+	is.Equal(len(batches), 2)
+
+	is.Equal(batches[0].(*upsertBatch).vectors[0].Id, "acme/doc1")
+	is.Equal(batches[1].(*deleteBatch).ids, []string{"acme/doc1"})
+}
+
+func TestVectorIDPolicy(t *testing.T) {
+	longID := strings.Repeat("a", maxVectorIDBytes+1)
+	longSum := sha256.Sum256([]byte(longID))
+
+	testCases := []struct {
+		name    string
+		policy  string
+		id      string
+		want    string
+		wantErr string
+	}{
+		{name: "valid id", policy: invalidIDPolicyReject, id: "key1", want: "key1"},
+		{name: "max length", policy: invalidIDPolicyReject, id: longID[1:], want: longID[1:]},
+		{name: "unicode id", policy: invalidIDPolicyReject, id: "clé", want: "clé"},
+		{name: "reject long id", policy: invalidIDPolicyReject, id: longID, wantErr: "longer than the maximum of 512 bytes"},
+		{name: "reject control character", policy: invalidIDPolicyReject, id: "key\x001", wantErr: "control character"},
+		{name: "reject invalid utf-8", policy: invalidIDPolicyReject, id: "key\xff", wantErr: "not valid UTF-8"},
+		{name: "sha256", policy: invalidIDPolicySHA256, id: longID, want: hex.EncodeToString(longSum[:])},
+		{
+			name:   "uuid5",
+			policy: invalidIDPolicyUUID5,
+			id:     longID,
+			want:   uuid.NewSHA1(uuid.NameSpaceURL, []byte(longID)).String(),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			is := is.New(t)
+
+			p, err := newVectorIDPolicy(InvalidIDConfig{Policy: tc.policy, UUIDNamespace: uuid.NameSpaceURL.String()})
+			is.NoErr(err)
+
+			got, original, err := p.apply(tc.id)
+			if tc.wantErr != "" {
+				is.True(err != nil)
+				is.True(strings.Contains(err.Error(), tc.wantErr))
+				return
+			}
+			is.NoErr(err)
+			is.Equal(got, tc.want)
+
+			if got != tc.id {
+				is.Equal(original, tc.id)
+			} else {
+				is.Equal(original, "")
+			}
+		})
+	}
+
+	t.Run("invalid uuid namespace", func(t *testing.T) {
+		is := is.New(t)
+
+		_, err := newVectorIDPolicy(InvalidIDConfig{Policy: invalidIDPolicyUUID5, UUIDNamespace: "ns"})
+		is.True(err != nil)
+	})
+}
+
+func TestVectorIDPolicy_OriginalKeyField(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+
+	parser, err := newVectorParser(defaultFieldsConfig, MetadataConfig{})
+	is.NoErr(err)
+	parser.idPolicy, err = newVectorIDPolicy(InvalidIDConfig{
+		Policy:           invalidIDPolicySHA256,
+		OriginalKeyField: "original_key",
+	})
+	is.NoErr(err)
+
+	longKey := strings.Repeat("k", maxVectorIDBytes+1)
+	colWriter := singleCollectionWriter{opts: writerOptions{parser: parser}}
+
+	batches, err := colWriter.buildBatches(ctx, []opencdc.Record{
+		{
+			Operation: opencdc.OperationCreate,
+			Key:       opencdc.RawData(longKey),
+			Payload:   opencdc.Change{After: opencdc.RawData(`{"values": [1, 2]}`)},
+		},
+		{Operation: opencdc.OperationDelete, Key: opencdc.RawData(longKey)},
+	})
+	is.NoErr(err)
+	is.Equal(len(batches), 2)
+
+	vec := batches[0].(*upsertBatch).vectors[0]
+	is.Equal(len(vec.Id), 64)
+	is.Equal(vec.Metadata.AsMap()["original_key"], longKey)
+
+	// deletes hash the key the same way
+	is.Equal(batches[1].(*deleteBatch).ids, []string{vec.Id})
+}
@@ -0,0 +1,550 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pinecone
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	sdk "github.com/conduitio/conduit-connector-sdk"
+)
+
+// allPayloadFields is the payloadFields value that copies all payload fields
+// except the vector fields.
+const allPayloadFields = "*"
+
+// MetadataConfig configures how the vector metadata is built.
+type MetadataConfig struct {
+	// PayloadFields is the list of payload fields copied into the vector
+	// metadata, keeping their JSON type so that they can be used in metadata
+	// filters. Fields are dot separated paths or JSON pointers, and are
+	// written under the last segment of their path. Use "*" to copy all the
+	// top level payload fields except the vector fields.
+	PayloadFields []string `json:"payloadFields"`
+
+	// DropInternal removes the record metadata keys starting with "opencdc."
+	// or "conduit.", which are added by Conduit.
+	DropInternal bool `json:"dropInternal"`
+
+	// Include is the list of patterns of the record metadata keys to keep.
+	// All keys are kept if empty. Patterns are globs, like "doc.*", or regular
+	// expressions prefixed with "regex:", like "regex:^doc\.[a-z]+$".
+	Include []string `json:"include"`
+
+	// Exclude is the list of patterns of the record metadata keys to remove,
+	// with the same syntax as include.
+	Exclude []string `json:"exclude"`
+
+	// Rename is the list of "old:new" rules renaming record metadata keys.
+	// Rules are applied after the keys are filtered.
+	Rename []string `json:"rename"`
+
+	// Coerce is the list of "key:type" rules converting string metadata
+	// values, like the record metadata, into typed values. Types are
+	// "number", "boolean", "list" (a list of strings split by
+	// listDelimiter) and "timestamp" (an RFC3339 timestamp converted to unix
+	// seconds). Keys are the ones after renaming.
+	Coerce []string `json:"coerce"`
+
+	// CoerceMode is either "strict", failing the record if a value can't be
+	// coerced, or "lenient", keeping the original string.
+	CoerceMode string `json:"coerceMode" default:"strict" validate:"inclusion=strict|lenient"`
+
+	// ListDelimiter is the delimiter of the values coerced into lists.
+	ListDelimiter string `json:"listDelimiter" default:","`
+
+	// MaxSize is the maximum size in bytes of the JSON encoded metadata of a
+	// vector. Pinecone rejects vectors with more than 40KB of metadata.
+	MaxSize int `json:"maxSize" default:"40960" validate:"gt=0"`
+
+	// OverflowPolicy is applied to vectors whose metadata is larger than
+	// maxSize. It's one of "fail", failing the write, "truncate", truncating
+	// the largest string values, or "drop", removing the keys listed in
+	// dropKeys in order. There's no policy routing only the oversized records
+	// to the dead letter queue, as a failed write nacks all the records after
+	// the failed one too.
+	OverflowPolicy string `json:"overflowPolicy" default:"fail" validate:"inclusion=fail|truncate|drop"`
+
+	// DropKeys is the list of low priority metadata keys removed, in order,
+	// until the metadata fits in maxSize. Required by the drop policy.
+	DropKeys []string `json:"dropKeys"`
+}
+
+const (
+	overflowPolicyFail     = "fail"
+	overflowPolicyTruncate = "truncate"
+	overflowPolicyDrop     = "drop"
+)
+
+// metadataSizeLimiter enforces the maximum size of the vector metadata.
+type metadataSizeLimiter struct {
+	maxSize  int
+	policy   string
+	dropKeys []string
+}
+
+func newMetadataSizeLimiter(cfg MetadataConfig) (*metadataSizeLimiter, error) {
+	if cfg.OverflowPolicy == overflowPolicyDrop && len(cfg.DropKeys) == 0 {
+		return nil, errors.New("dropKeys is required by the drop overflow policy")
+	}
+
+	policy := cfg.OverflowPolicy
+	if policy == "" {
+		policy = overflowPolicyFail
+	}
+
+	return &metadataSizeLimiter{
+		maxSize:  cfg.MaxSize,
+		policy:   policy,
+		dropKeys: cfg.DropKeys,
+	}, nil
+}
+
+func metadataSize(metadata map[string]any) int {
+	return len(jsonString(metadata))
+}
+
+// apply applies the overflow policy, in place, if the metadata of the vector
+// is too large. A maxSize of zero disables the limit.
+func (l *metadataSizeLimiter) apply(ctx context.Context, id string, metadata map[string]any) error {
+	size := metadataSize(metadata)
+	if l.maxSize == 0 || size <= l.maxSize {
+		return nil
+	}
+
+	logger := sdk.Logger(ctx).Warn().
+		Str("vector_id", id).
+		Int("metadata_size", size).
+		Int("max_size", l.maxSize).
+		Str("policy", l.policy)
+	tooLargeErr := fmt.Errorf("metadata of vector %q is %d bytes, larger than the maximum of %d bytes", id, size, l.maxSize)
+
+	switch l.policy {
+	case overflowPolicyTruncate:
+		if !l.truncate(metadata) {
+			return fmt.Errorf("%w, and can't be truncated enough", tooLargeErr)
+		}
+		logger.Msg("truncated vector metadata larger than the maximum size")
+	case overflowPolicyDrop:
+		if !l.drop(metadata) {
+			return fmt.Errorf("%w, even without the drop keys", tooLargeErr)
+		}
+		logger.Msg("dropped keys of vector metadata larger than the maximum size")
+	default:
+		return tooLargeErr
+	}
+
+	return nil
+}
+
+// truncate shortens the largest string values until the metadata fits, and
+// returns whether it does.
+func (l *metadataSizeLimiter) truncate(metadata map[string]any) bool {
+	for {
+		size := metadataSize(metadata)
+		if size <= l.maxSize {
+			return true
+		}
+
+		var largestKey, largest string
+		for key, value := range metadata {
+			if str, ok := value.(string); ok && len(str) > len(largest) {
+				largestKey, largest = key, str
+			}
+		}
+		if largest == "" {
+			return false
+		}
+
+		// escaped characters take more than a byte in JSON, so the loop
+		// keeps truncating until the metadata fits
+		n := max(len(largest)-(size-l.maxSize), 0)
+		for n > 0 && !utf8.RuneStart(largest[n]) {
+			n--
+		}
+		metadata[largestKey] = largest[:n]
+	}
+}
+
+// drop removes the drop keys in order until the metadata fits, and returns
+// whether it does.
+func (l *metadataSizeLimiter) drop(metadata map[string]any) bool {
+	for _, key := range l.dropKeys {
+		if metadataSize(metadata) <= l.maxSize {
+			return true
+		}
+		delete(metadata, key)
+	}
+	return metadataSize(metadata) <= l.maxSize
+}
+
+const (
+	coerceModeStrict  = "strict"
+	coerceModeLenient = "lenient"
+)
+
+// coerceFunc converts a string metadata value into a typed value.
+type coerceFunc func(string) (any, error)
+
+// metadataCoercer converts string metadata values into typed values.
+type metadataCoercer struct {
+	rules  map[string]coerceFunc
+	strict bool
+}
+
+// newMetadataCoercer returns nil if no value is coerced.
+func newMetadataCoercer(cfg MetadataConfig) (*metadataCoercer, error) {
+	if len(cfg.Coerce) == 0 {
+		return nil, nil //nolint:nilnil // no value is coerced
+	}
+
+	c := &metadataCoercer{
+		rules:  make(map[string]coerceFunc),
+		strict: cfg.CoerceMode != coerceModeLenient,
+	}
+
+	for _, rule := range cfg.Coerce {
+		key, typ, ok := strings.Cut(rule, ":")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid coerce rule %q, expected \"key:type\"", rule)
+		}
+		if _, ok := c.rules[key]; ok {
+			return nil, fmt.Errorf("key %q is coerced more than once", key)
+		}
+
+		var fn coerceFunc
+		switch typ {
+		case "number":
+			fn = coerceNumber
+		case "boolean":
+			fn = coerceBoolean
+		case "list":
+			if cfg.ListDelimiter == "" {
+				return nil, errors.New("list delimiter must not be empty")
+			}
+			fn = coerceList(cfg.ListDelimiter)
+		case "timestamp":
+			fn = coerceTimestamp
+		default:
+			return nil, fmt.Errorf("invalid coerce type %q for key %q, expected number, boolean, list or timestamp", typ, key)
+		}
+		c.rules[key] = fn
+	}
+
+	return c, nil
+}
+
+// apply coerces the string values of the metadata in place. Values that
+// already have a type are left as is.
+func (c *metadataCoercer) apply(metadata map[string]any) error {
+	for key, fn := range c.rules {
+		str, ok := metadata[key].(string)
+		if !ok {
+			continue
+		}
+
+		value, err := fn(str)
+		if err != nil {
+			if c.strict {
+				return fmt.Errorf("failed to coerce metadata key %q: %w", key, err)
+			}
+			continue
+		}
+		metadata[key] = value
+	}
+	return nil
+}
+
+func coerceNumber(s string) (any, error) {
+	f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid number %q: %w", s, err)
+	}
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil, fmt.Errorf("invalid number %q: not a finite number", s)
+	}
+	return f, nil
+}
+
+func coerceBoolean(s string) (any, error) {
+	b, err := strconv.ParseBool(strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("invalid boolean %q: %w", s, err)
+	}
+	return b, nil
+}
+
+func coerceList(delimiter string) coerceFunc {
+	return func(s string) (any, error) {
+		if s == "" {
+			return []any{}, nil
+		}
+
+		parts := strings.Split(s, delimiter)
+		items := make([]any, len(parts))
+		for i, part := range parts {
+			items[i] = strings.TrimSpace(part)
+		}
+		return items, nil
+	}
+}
+
+func coerceTimestamp(s string) (any, error) {
+	t, err := time.Parse(time.RFC3339, strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("invalid RFC3339 timestamp %q: %w", s, err)
+	}
+	return float64(t.Unix()), nil
+}
+
+// internalKeyPrefixes are the prefixes of the metadata keys added by Conduit.
+var internalKeyPrefixes = []string{"opencdc.", "conduit."}
+
+// regexPatternPrefix marks the key patterns that are regular expressions.
+const regexPatternPrefix = "regex:"
+
+// keyMatcher reports whether a metadata key matches a pattern.
+type keyMatcher func(key string) bool
+
+func parseKeyPattern(pattern string) (keyMatcher, error) {
+	if expr, ok := strings.CutPrefix(pattern, regexPatternPrefix); ok {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression %q: %w", expr, err)
+		}
+		return re.MatchString, nil
+	}
+
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid glob %q: %w", pattern, err)
+	}
+	return func(key string) bool {
+		ok, _ := path.Match(pattern, key)
+		return ok
+	}, nil
+}
+
+func parseKeyPatterns(patterns []string) ([]keyMatcher, error) {
+	matchers := make([]keyMatcher, len(patterns))
+	for i, pattern := range patterns {
+		m, err := parseKeyPattern(pattern)
+		if err != nil {
+			return nil, err
+		}
+		matchers[i] = m
+	}
+	return matchers, nil
+}
+
+func matchesAny(matchers []keyMatcher, key string) bool {
+	for _, m := range matchers {
+		if m(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// metadataFilter removes and renames the keys of the record metadata, before
+// it's merged with the payload fields into the vector metadata.
+type metadataFilter struct {
+	dropInternal bool
+	include      []keyMatcher
+	exclude      []keyMatcher
+	rename       map[string]string
+}
+
+// newMetadataFilter returns nil if no key is filtered nor renamed.
+func newMetadataFilter(cfg MetadataConfig) (*metadataFilter, error) {
+	if !cfg.DropInternal && len(cfg.Include) == 0 && len(cfg.Exclude) == 0 && len(cfg.Rename) == 0 {
+		return nil, nil //nolint:nilnil // no filter configured
+	}
+
+	f := &metadataFilter{
+		dropInternal: cfg.DropInternal,
+		rename:       make(map[string]string),
+	}
+
+	var err error
+	if f.include, err = parseKeyPatterns(cfg.Include); err != nil {
+		return nil, fmt.Errorf("invalid include pattern: %w", err)
+	}
+	if f.exclude, err = parseKeyPatterns(cfg.Exclude); err != nil {
+		return nil, fmt.Errorf("invalid exclude pattern: %w", err)
+	}
+
+	for _, rule := range cfg.Rename {
+		from, to, ok := strings.Cut(rule, ":")
+		if !ok || from == "" || to == "" {
+			return nil, fmt.Errorf("invalid rename rule %q, expected \"old:new\"", rule)
+		}
+		if _, ok := f.rename[from]; ok {
+			return nil, fmt.Errorf("key %q is renamed more than once", from)
+		}
+		for _, other := range f.rename {
+			if other == to {
+				return nil, fmt.Errorf("more than one key is renamed to %q", to)
+			}
+		}
+		f.rename[from] = to
+	}
+
+	return f, nil
+}
+
+func (f *metadataFilter) keep(key string) bool {
+	if f.dropInternal {
+		for _, prefix := range internalKeyPrefixes {
+			if strings.HasPrefix(key, prefix) {
+				return false
+			}
+		}
+	}
+	if len(f.include) > 0 && !matchesAny(f.include, key) {
+		return false
+	}
+	return !matchesAny(f.exclude, key)
+}
+
+// apply filters and renames the keys of the metadata in place. A renamed key
+// replaces the existing key with the new name, if any.
+func (f *metadataFilter) apply(metadata map[string]any) {
+	for key := range metadata {
+		if !f.keep(key) {
+			delete(metadata, key)
+		}
+	}
+
+	// rename in two steps, so that rules swapping keys work
+	renamed := make(map[string]any)
+	for from, to := range f.rename {
+		if value, ok := metadata[from]; ok {
+			delete(metadata, from)
+			renamed[to] = value
+		}
+	}
+	maps.Copy(metadata, renamed)
+}
+
+// payloadFieldsParser copies payload fields into the vector metadata.
+type payloadFieldsParser struct {
+	// all is true if all top level fields are copied, except the excluded
+	// ones.
+	all      bool
+	excluded []string
+
+	paths []fieldPath
+}
+
+// newPayloadFieldsParser returns nil if no payload field is copied. The
+// vectorFields are excluded when all fields are copied.
+func newPayloadFieldsParser(fields []string, vectorFields []fieldPath) (*payloadFieldsParser, error) {
+	if len(fields) == 0 {
+		return nil, nil //nolint:nilnil // no payload field is copied
+	}
+
+	if slices.Contains(fields, allPayloadFields) {
+		if len(fields) > 1 {
+			return nil, fmt.Errorf("%q can't be combined with other payload fields", allPayloadFields)
+		}
+
+		p := &payloadFieldsParser{all: true}
+		for _, path := range vectorFields {
+			if len(path) > 0 {
+				p.excluded = append(p.excluded, path[0])
+			}
+		}
+		return p, nil
+	}
+
+	p := &payloadFieldsParser{}
+	for _, field := range fields {
+		path, err := parseFieldPath(field)
+		if err != nil {
+			return nil, fmt.Errorf("invalid payload field: %w", err)
+		}
+		p.paths = append(p.paths, path)
+	}
+	return p, nil
+}
+
+// parse returns the metadata entries of the payload fields. Missing and null
+// fields are skipped.
+func (p *payloadFieldsParser) parse(payload any) (map[string]any, error) {
+	metadata := make(map[string]any)
+
+	if p.all {
+		obj, ok := payload.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("payload is a %T, expected an object", payload)
+		}
+
+		for key, value := range obj {
+			if slices.Contains(p.excluded, key) || value == nil {
+				continue
+			}
+			metadata[key] = metadataValue(value)
+		}
+		return metadata, nil
+	}
+
+	for _, path := range p.paths {
+		value, ok := path.get(payload)
+		if !ok || value == nil {
+			continue
+		}
+		metadata[path[len(path)-1]] = metadataValue(value)
+	}
+	return metadata, nil
+}
+
+// metadataValue returns the value as is if Pinecone supports its type, which
+// are strings, numbers, booleans and lists of strings. Other values are JSON
+// encoded into a string.
+func metadataValue(value any) any {
+	switch v := value.(type) {
+	case string, float64, bool:
+		return v
+	case []any:
+		for _, item := range v {
+			if _, ok := item.(string); !ok {
+				return jsonString(v)
+			}
+		}
+		return v
+	default:
+		return jsonString(v)
+	}
+}
+
+func jsonString(v any) string {
+	bs, err := json.Marshal(v)
+	if err != nil {
+		// should never happen, the value was unmarshaled from JSON
+		panic(fmt.Errorf("failed to marshal metadata value: %w", err))
+	}
+	return string(bs)
+}
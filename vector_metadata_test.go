@@ -0,0 +1,406 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pinecone
+
+import (
+	"context"
+	"maps"
+	"strings"
+	"testing"
+
+	"github.com/conduitio/conduit-commons/opencdc"
+	"github.com/matryer/is"
+)
+
+const testPayload = `{
+	"values": [1, 2],
+	"sparse_values": {"indices": [3], "values": [0.5]},
+	"title": "hello",
+	"pages": 3,
+	"published": true,
+	"tags": ["a", "b"],
+	"ratings": [4, 5],
+	"author": {"name": "jane"},
+	"deleted": null
+}`
+
+func parseTestPayload(is *is.I, metadataCfg MetadataConfig) map[string]any {
+	parser, err := newVectorParser(defaultFieldsConfig, metadataCfg)
+	is.NoErr(err)
+
+	vec, err := parser.parse(context.Background(), opencdc.Record{
+		Key:      opencdc.RawData("key1"),
+		Metadata: opencdc.Metadata{"title": "from record", "prop": "val"},
+		Payload:  opencdc.Change{After: opencdc.RawData(testPayload)},
+	})
+	is.NoErr(err)
+
+	return vec.Metadata.AsMap()
+}
+
+func TestPayloadFields(t *testing.T) {
+	t.Run("listed fields", func(t *testing.T) {
+		is := is.New(t)
+
+		metadata := parseTestPayload(is, MetadataConfig{
+			PayloadFields: []string{"title", "pages", "/published", "tags", "author.name", "missing", "deleted"},
+		})
+
+		is.Equal(metadata, map[string]any{
+			"prop":      "val",
+			"title":     "hello", // payload fields take precedence
+			"pages":     float64(3),
+			"published": true,
+			"tags":      []any{"a", "b"},
+			"name":      "jane",
+		})
+	})
+
+	t.Run("all fields", func(t *testing.T) {
+		is := is.New(t)
+
+		metadata := parseTestPayload(is, MetadataConfig{PayloadFields: []string{"*"}})
+
+		is.Equal(metadata, map[string]any{
+			"prop":      "val",
+			"title":     "hello",
+			"pages":     float64(3),
+			"published": true,
+			"tags":      []any{"a", "b"},
+			// types not supported by Pinecone are JSON encoded
+			"ratings": "[4,5]",
+			"author":  `{"name":"jane"}`,
+		})
+	})
+
+	t.Run("all fields combined with others", func(t *testing.T) {
+		is := is.New(t)
+
+		_, err := newVectorParser(defaultFieldsConfig, MetadataConfig{PayloadFields: []string{"*", "title"}})
+		is.True(err != nil)
+	})
+}
+
+func TestMetadataObject(t *testing.T) {
+	is := is.New(t)
+
+	fields := defaultFieldsConfig
+	fields.Metadata = "doc"
+	parser, err := newVectorParser(fields, MetadataConfig{})
+	is.NoErr(err)
+
+	vec, err := parser.parse(context.Background(), opencdc.Record{
+		Key:      opencdc.RawData("key1"),
+		Metadata: opencdc.Metadata{"deleted": "from record"},
+		Payload: opencdc.Change{After: opencdc.RawData(`{
+			"values": [1, 2],
+			"doc": {
+				"title": "hello",
+				"tags": ["a", "b"],
+				"ratings": [4, 5],
+				"author": {"name": "jane"},
+				"deleted": null
+			}
+		}`)},
+	})
+	is.NoErr(err)
+
+	// the object is normalized like the payload fields
+	is.Equal(vec.Metadata.AsMap(), map[string]any{
+		"title":   "hello",
+		"tags":    []any{"a", "b"},
+		"ratings": "[4,5]",
+		"author":  `{"name":"jane"}`,
+		"deleted": "from record",
+	})
+}
+
+func TestMetadataFilter(t *testing.T) {
+	metadata := func() map[string]any {
+		return map[string]any{
+			"opencdc.readAt":              "1",
+			"opencdc.collection":          "ns",
+			"conduit.source.connector.id": "src",
+			"doc.title":                   "hello",
+			"doc.year":                    float64(2024),
+			"author":                      "jane",
+		}
+	}
+
+	testCases := []struct {
+		name string
+		cfg  MetadataConfig
+		want map[string]any
+	}{
+		{
+			name: "drop internal",
+			cfg:  MetadataConfig{DropInternal: true},
+			want: map[string]any{"doc.title": "hello", "doc.year": float64(2024), "author": "jane"},
+		},
+		{
+			name: "include glob",
+			cfg:  MetadataConfig{Include: []string{"doc.*"}},
+			want: map[string]any{"doc.title": "hello", "doc.year": float64(2024)},
+		},
+		{
+			name: "exclude regex",
+			cfg:  MetadataConfig{Exclude: []string{`regex:^(opencdc|conduit)\.`, "doc.year"}},
+			want: map[string]any{"doc.title": "hello", "author": "jane"},
+		},
+		{
+			name: "rename after filtering",
+			cfg: MetadataConfig{
+				Include: []string{"doc.*", "opencdc.collection"},
+				Rename:  []string{"opencdc.collection:namespace", "doc.title:title"},
+			},
+			want: map[string]any{"namespace": "ns", "title": "hello", "doc.year": float64(2024)},
+		},
+		{
+			name: "swap keys",
+			cfg:  MetadataConfig{Include: []string{"doc.*"}, Rename: []string{"doc.title:doc.year", "doc.year:doc.title"}},
+			want: map[string]any{"doc.title": float64(2024), "doc.year": "hello"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			is := is.New(t)
+
+			f, err := newMetadataFilter(tc.cfg)
+			is.NoErr(err)
+
+			got := metadata()
+			f.apply(got)
+			is.Equal(got, tc.want)
+		})
+	}
+}
+
+func TestMetadataFilter_PayloadFields(t *testing.T) {
+	is := is.New(t)
+
+	// only the record metadata is filtered, the payload fields are kept
+	metadata := parseTestPayload(is, MetadataConfig{
+		PayloadFields: []string{"title", "pages"},
+		Include:       []string{"prop"},
+		Rename:        []string{"pages:count"},
+	})
+
+	is.Equal(metadata, map[string]any{
+		"prop":  "val",
+		"title": "hello",
+		"pages": float64(3),
+	})
+}
+
+func TestMetadataFilter_InvalidConfig(t *testing.T) {
+	testCases := []struct {
+		name string
+		cfg  MetadataConfig
+	}{
+		{name: "invalid glob", cfg: MetadataConfig{Include: []string{"doc.["}}},
+		{name: "invalid regex", cfg: MetadataConfig{Exclude: []string{"regex:("}}},
+		{name: "invalid rename rule", cfg: MetadataConfig{Rename: []string{"title"}}},
+		{name: "key renamed twice", cfg: MetadataConfig{Rename: []string{"a:b", "a:c"}}},
+		{name: "keys renamed to the same key", cfg: MetadataConfig{Rename: []string{"a:c", "b:c"}}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			is := is.New(t)
+
+			_, err := newMetadataFilter(tc.cfg)
+			is.True(err != nil)
+		})
+	}
+}
+
+func TestMetadataCoercer(t *testing.T) {
+	cfg := MetadataConfig{
+		Coerce: []string{
+			"year:number",
+			"published:boolean",
+			"tags:list",
+			"createdAt:timestamp",
+			"typed:number",
+			"missing:number",
+		},
+		CoerceMode:    coerceModeStrict,
+		ListDelimiter: "|",
+	}
+
+	t.Run("coerces string values", func(t *testing.T) {
+		is := is.New(t)
+
+		c, err := newMetadataCoercer(cfg)
+		is.NoErr(err)
+
+		metadata := map[string]any{
+			"year":      "2024",
+			"published": "true",
+			"tags":      "a| b|c",
+			"createdAt": "2024-01-02T03:04:05Z",
+			"typed":     true, // not a string, left as is
+			"other":     "1",
+		}
+		is.NoErr(c.apply(metadata))
+
+		is.Equal(metadata, map[string]any{
+			"year":      float64(2024),
+			"published": true,
+			"tags":      []any{"a", "b", "c"},
+			"createdAt": float64(1704164645),
+			"typed":     true,
+			"other":     "1",
+		})
+	})
+
+	invalid := []map[string]any{
+		{"year": "twenty"},
+		{"year": "NaN"},
+		{"published": "maybe"},
+		{"createdAt": "yesterday"},
+	}
+
+	t.Run("strict mode fails", func(t *testing.T) {
+		c, err := newMetadataCoercer(cfg)
+		is.New(t).NoErr(err)
+
+		for _, metadata := range invalid {
+			is.New(t).True(c.apply(metadata) != nil)
+		}
+	})
+
+	t.Run("lenient mode keeps strings", func(t *testing.T) {
+		lenientCfg := cfg
+		lenientCfg.CoerceMode = coerceModeLenient
+
+		c, err := newMetadataCoercer(lenientCfg)
+		is.New(t).NoErr(err)
+
+		for _, metadata := range invalid {
+			is := is.New(t)
+
+			want := maps.Clone(metadata)
+			is.NoErr(c.apply(metadata))
+			is.Equal(metadata, want)
+		}
+	})
+
+	t.Run("invalid rules", func(t *testing.T) {
+		for _, rule := range []string{"year", ":number", "year:date"} {
+			is := is.New(t)
+
+			_, err := newMetadataCoercer(MetadataConfig{Coerce: []string{rule}, ListDelimiter: ","})
+			is.True(err != nil)
+		}
+	})
+}
+
+func TestMetadataSizeLimiter(t *testing.T) {
+	ctx := context.Background()
+	metadata := func() map[string]any {
+		return map[string]any{
+			"title":   "hello",
+			"body":    strings.Repeat("a", 100),
+			"summary": strings.Repeat("b", 50),
+			"pages":   float64(3),
+		}
+	}
+
+	t.Run("fits", func(t *testing.T) {
+		is := is.New(t)
+
+		l, err := newMetadataSizeLimiter(MetadataConfig{MaxSize: 1000})
+		is.NoErr(err)
+
+		got := metadata()
+		is.NoErr(l.apply(ctx, "key1", got))
+		is.Equal(got, metadata())
+	})
+
+	t.Run("fail", func(t *testing.T) {
+		is := is.New(t)
+
+		l, err := newMetadataSizeLimiter(MetadataConfig{MaxSize: 100, OverflowPolicy: overflowPolicyFail})
+		is.NoErr(err)
+
+		err = l.apply(ctx, "key1", metadata())
+		is.True(err != nil)
+		is.True(strings.Contains(err.Error(), `"key1"`))
+	})
+
+	t.Run("truncate", func(t *testing.T) {
+		is := is.New(t)
+
+		l, err := newMetadataSizeLimiter(MetadataConfig{MaxSize: 150, OverflowPolicy: overflowPolicyTruncate})
+		is.NoErr(err)
+
+		got := metadata()
+		is.NoErr(l.apply(ctx, "key1", got))
+		is.True(metadataSize(got) <= 150)
+		is.Equal(got["title"], "hello")
+		is.Equal(got["pages"], float64(3))
+		is.True(strings.HasPrefix(strings.Repeat("a", 100), got["body"].(string)))
+	})
+
+	t.Run("truncate not enough", func(t *testing.T) {
+		is := is.New(t)
+
+		l, err := newMetadataSizeLimiter(MetadataConfig{MaxSize: 10, OverflowPolicy: overflowPolicyTruncate})
+		is.NoErr(err)
+
+		is.True(l.apply(ctx, "key1", metadata()) != nil)
+	})
+
+	t.Run("drop", func(t *testing.T) {
+		is := is.New(t)
+
+		l, err := newMetadataSizeLimiter(MetadataConfig{
+			MaxSize:        150,
+			OverflowPolicy: overflowPolicyDrop,
+			DropKeys:       []string{"missing", "body", "summary"},
+		})
+		is.NoErr(err)
+
+		got := metadata()
+		is.NoErr(l.apply(ctx, "key1", got))
+		is.Equal(got, map[string]any{
+			"title":   "hello",
+			"summary": strings.Repeat("b", 50),
+			"pages":   float64(3),
+		})
+	})
+
+	t.Run("drop not enough", func(t *testing.T) {
+		is := is.New(t)
+
+		l, err := newMetadataSizeLimiter(MetadataConfig{
+			MaxSize:        50,
+			OverflowPolicy: overflowPolicyDrop,
+			DropKeys:       []string{"summary"},
+		})
+		is.NoErr(err)
+
+		is.True(l.apply(ctx, "key1", metadata()) != nil)
+	})
+
+	t.Run("drop without keys", func(t *testing.T) {
+		is := is.New(t)
+
+		_, err := newMetadataSizeLimiter(MetadataConfig{MaxSize: 100, OverflowPolicy: overflowPolicyDrop})
+		is.True(err != nil)
+	})
+}
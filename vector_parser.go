@@ -0,0 +1,615 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pinecone
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/conduitio/conduit-commons/opencdc"
+	"github.com/pinecone-io/go-pinecone/pinecone"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// FieldsConfig names the fields of the record payload that hold the vector.
+// Fields are either dot separated paths, like "doc.embedding", or JSON
+// pointers, like "/doc/embedding".
+type FieldsConfig struct {
+	// Values is the field holding the dense vector values.
+	Values string `json:"values" default:"values"`
+
+	// SparseIndices is the field holding the sparse vector indices.
+	SparseIndices string `json:"sparseIndices" default:"sparse_values.indices"`
+
+	// SparseValues is the field holding the sparse vector values.
+	SparseValues string `json:"sparseValues" default:"sparse_values.values"`
+
+	// Metadata is the field holding an object whose entries are added to the
+	// vector metadata, on top of the record metadata. No payload field is
+	// added to the metadata if empty.
+	Metadata string `json:"metadata"`
+
+	// Required is the vector components that records must have. It's one of
+	// "any", requiring dense or sparse values, "dense", "sparse" or "both".
+	Required string `json:"required" default:"any" validate:"inclusion=any|dense|sparse|both"`
+
+	// Chunks is the field holding a list of chunks, fanning the record out
+	// into one vector per chunk. Each chunk is an object holding the values,
+	// sparse values and metadata fields of its vector. Records are written as
+	// a single vector if empty.
+	Chunks string `json:"chunks"`
+
+	// ChunkID is the chunk field holding the vector ID of the chunk. If
+	// empty, the ID of a chunk is the vector ID of the record followed by
+	// chunkSeparator and the position of the chunk, like "doc123#0".
+	ChunkID string `json:"chunkID"`
+
+	// ChunkSeparator separates the vector ID of the record from the position
+	// of the chunk in the generated chunk IDs.
+	ChunkSeparator string `json:"chunkSeparator" default:"#"`
+}
+
+const (
+	requiredAny    = "any"
+	requiredDense  = "dense"
+	requiredSparse = "sparse"
+	requiredBoth   = "both"
+)
+
+// defaultFieldsConfig matches the payload shape produced by the source.
+var defaultFieldsConfig = FieldsConfig{
+	Values:         "values",
+	SparseIndices:  "sparse_values.indices",
+	SparseValues:   "sparse_values.values",
+	Required:       requiredAny,
+	ChunkSeparator: "#",
+}
+
+// defaultVectorParser parses payloads with the default field mapping.
+var defaultVectorParser = func() *vectorParser {
+	p, err := newVectorParser(defaultFieldsConfig, MetadataConfig{})
+	if err != nil {
+		// should never happen, the default config is valid
+		panic(err)
+	}
+	return p
+}()
+
+// vectorParser builds Pinecone vectors out of records.
+type vectorParser struct {
+	values        fieldPath
+	sparseIndices fieldPath
+	sparseValues  fieldPath
+	// metadata is nil if the payload metadata isn't mapped.
+	metadata fieldPath
+	// required is the vector components that records must have.
+	required string
+	// chunks is nil if records aren't fanned out into chunks.
+	chunks *chunksParser
+
+	// payloadFields is nil if no payload field is copied into the metadata.
+	payloadFields *payloadFieldsParser
+	// metadataFilter is nil if the metadata keys aren't filtered.
+	metadataFilter *metadataFilter
+	// metadataCoercer is nil if no metadata value is coerced.
+	metadataCoercer *metadataCoercer
+	// metadataSize enforces the maximum metadata size, disabled if zero.
+	metadataSize *metadataSizeLimiter
+
+	// ids is nil if the record key is the vector ID.
+	ids *vectorIDTemplate
+	// idPolicy is nil if invalid vector IDs are rejected.
+	idPolicy *vectorIDPolicy
+	// index is nil if the index wasn't described, in which case only the
+	// checks independent of the index are run.
+	index *indexDescription
+	// embedText is nil if vectors without dense values aren't embedded.
+	embedText *textSource
+	// sparseText and sparseEncoder are nil if the sparse values of vectors
+	// that have none aren't encoded out of a text.
+	sparseText    *textSource
+	sparseEncoder *bm25Encoder
+	// recordText is nil unless vectors are upserted as text records, embedded
+	// by the index, in which case their values are ignored.
+	recordText *textSource
+}
+
+func newVectorParser(cfg FieldsConfig, metadataCfg MetadataConfig) (*vectorParser, error) {
+	p := vectorParser{required: cfg.Required}
+	var err error
+
+	if p.values, err = parseFieldPath(cfg.Values); err != nil {
+		return nil, fmt.Errorf("invalid values field: %w", err)
+	}
+	if p.sparseIndices, err = parseFieldPath(cfg.SparseIndices); err != nil {
+		return nil, fmt.Errorf("invalid sparse indices field: %w", err)
+	}
+	if p.sparseValues, err = parseFieldPath(cfg.SparseValues); err != nil {
+		return nil, fmt.Errorf("invalid sparse values field: %w", err)
+	}
+	if cfg.Metadata != "" {
+		if p.metadata, err = parseFieldPath(cfg.Metadata); err != nil {
+			return nil, fmt.Errorf("invalid metadata field: %w", err)
+		}
+	}
+
+	if p.chunks, err = newChunksParser(cfg); err != nil {
+		return nil, err
+	}
+
+	vectorFields := []fieldPath{p.values, p.sparseIndices, p.sparseValues, p.metadata}
+	if p.chunks != nil {
+		vectorFields = append(vectorFields, p.chunks.field)
+	}
+	if p.payloadFields, err = newPayloadFieldsParser(metadataCfg.PayloadFields, vectorFields); err != nil {
+		return nil, err
+	}
+	if p.metadataFilter, err = newMetadataFilter(metadataCfg); err != nil {
+		return nil, err
+	}
+	if p.metadataCoercer, err = newMetadataCoercer(metadataCfg); err != nil {
+		return nil, err
+	}
+	if p.metadataSize, err = newMetadataSizeLimiter(metadataCfg); err != nil {
+		return nil, err
+	}
+
+	return &p, nil
+}
+
+// parseUpsert parses the record into a validated vector. If embeddings are
+// enabled and the vector has no dense values, it's returned unvalidated with
+// the text to embed into them. It's validated once embedded.
+func (p *vectorParser) parseUpsert(ctx context.Context, rec opencdc.Record) (*pinecone.Vector, string, error) {
+	payload, err := unmarshalPayload(rec.Payload.After)
+	if err != nil {
+		return nil, "", err
+	}
+
+	id, originalID, err := p.vectorID(rec)
+	if err != nil {
+		return nil, "", err
+	}
+	vec, err := p.buildVector(ctx, rec, payload, payload, id, originalID)
+	if err != nil {
+		return nil, "", err
+	}
+	return p.prepare(rec, vec, payload)
+}
+
+// prepare encodes the sparse values of the vector out of the record or the
+// object holding the vector fields if it has none. It then validates the
+// vector, or returns the text to embed into its dense values. Vectors
+// upserted as text records are only returned with their text.
+func (p *vectorParser) prepare(rec opencdc.Record, vec *pinecone.Vector, obj any) (*pinecone.Vector, string, error) {
+	if p.recordText != nil {
+		text, err := p.recordText.text(rec, obj)
+		if err != nil {
+			return nil, "", fmt.Errorf("vector %q has no text to upsert as a record: %w", vec.Id, err)
+		}
+		return vec, text, nil
+	}
+
+	if p.sparseEncoder != nil && vec.SparseValues == nil {
+		text, err := p.sparseText.text(rec, obj)
+		if err != nil {
+			return nil, "", fmt.Errorf("vector %q has no sparse values to encode: %w", vec.Id, err)
+		}
+		vec.SparseValues = p.sparseEncoder.encode(text)
+	}
+
+	if p.embedText == nil || vec.Values != nil {
+		return vec, "", p.validate(vec)
+	}
+
+	text, err := p.embedText.text(rec, obj)
+	if err != nil {
+		return nil, "", fmt.Errorf("vector %q has no dense values to embed: %w", vec.Id, err)
+	}
+	return vec, text, nil
+}
+
+// validate checks that the vector has the required components and would be
+// accepted by the index.
+func (p *vectorParser) validate(vec *pinecone.Vector) error {
+	if err := p.checkRequired(vec); err != nil {
+		return err
+	}
+	return validateVector(vec, p.index)
+}
+
+// parseUpdate parses the record into a vector used to update an existing
+// vector. Its values are dropped unless they're updated too, and validated
+// otherwise, as the vector keeps its values if the record has none.
+func (p *vectorParser) parseUpdate(ctx context.Context, rec opencdc.Record, values bool) (*pinecone.Vector, error) {
+	vec, err := p.parseRecord(ctx, rec)
+	if err != nil {
+		return nil, err
+	}
+
+	if !values {
+		vec.Values, vec.SparseValues = nil, nil
+		return vec, nil
+	}
+	if vec.Values != nil || vec.SparseValues != nil {
+		if err := validateVector(vec, p.index); err != nil {
+			return nil, err
+		}
+	}
+	return vec, nil
+}
+
+// canUpdate returns whether vectors can be written with update requests,
+// which need the values of the record as they are.
+func (p *vectorParser) canUpdate() bool {
+	// the values of each chunk, or the encoded texts, could have changed
+	return p.chunks == nil && p.embedText == nil && p.sparseEncoder == nil && p.recordText == nil
+}
+
+// valuesUnchanged returns whether the payload of the record has no vector
+// values, or the same values as the payload before. Payloads that can't be
+// parsed are reported as changed, so that the error surfaces on upsert.
+func (p *vectorParser) valuesUnchanged(rec opencdc.Record) bool {
+	if !p.canUpdate() {
+		return false
+	}
+
+	_, values, sparse, err := p.parseValues(rec.Payload.After)
+	if err != nil {
+		return false
+	}
+	if values == nil && sparse == nil {
+		return true
+	}
+	if rec.Payload.Before == nil {
+		return false
+	}
+
+	_, beforeValues, beforeSparse, err := p.parseValues(rec.Payload.Before)
+	if err != nil {
+		return false
+	}
+	if (sparse == nil) != (beforeSparse == nil) {
+		return false
+	}
+	if sparse != nil && (!slices.Equal(sparse.Indices, beforeSparse.Indices) ||
+		!slices.Equal(sparse.Values, beforeSparse.Values)) {
+		return false
+	}
+	return slices.Equal(values, beforeValues)
+}
+
+// parseValues parses the payload, and returns it with the dense and sparse
+// values of the vector, nil if missing or empty.
+func (p *vectorParser) parseValues(data opencdc.Data) (any, []float32, *pinecone.SparseValues, error) {
+	payload, err := unmarshalPayload(data)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	values, sparse, err := p.vectorValues(payload)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return payload, values, sparse, nil
+}
+
+func unmarshalPayload(data opencdc.Data) (any, error) {
+	if data == nil {
+		return nil, errors.New("record has no payload")
+	}
+
+	// structured data is marshaled too, so that both data types are handled
+	// the same way
+	var payload any
+	if err := json.Unmarshal(data.Bytes(), &payload); err != nil {
+		return nil, fmt.Errorf("failed to parse record json: %w", err)
+	}
+	return payload, nil
+}
+
+// vectorValues returns the dense and sparse values of the vector in the
+// object, either the payload or a chunk, nil if missing or empty.
+func (p *vectorParser) vectorValues(obj any) ([]float32, *pinecone.SparseValues, error) {
+	values, err := p.float32s(obj, p.values)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sparseIndices, err := p.uint32s(obj, p.sparseIndices)
+	if err != nil {
+		return nil, nil, err
+	}
+	sparseValues, err := p.float32s(obj, p.sparseValues)
+	if err != nil {
+		return nil, nil, err
+	}
+	if (sparseIndices == nil) != (sparseValues == nil) {
+		return nil, nil, fmt.Errorf("sparse indices %q and values %q must be both set or both missing",
+			p.sparseIndices, p.sparseValues)
+	}
+
+	// only the components present in the payload are sent, so that sparse
+	// only vectors can be written to sparse indexes
+	if len(values) == 0 {
+		values = nil
+	}
+	var sparse *pinecone.SparseValues
+	if len(sparseIndices) > 0 || len(sparseValues) > 0 {
+		sparse = &pinecone.SparseValues{
+			Indices: sparseIndices,
+			Values:  sparseValues,
+		}
+	}
+
+	return values, sparse, nil
+}
+
+// parseRecord parses the record into a vector, without validating it.
+func (p *vectorParser) parseRecord(ctx context.Context, rec opencdc.Record) (*pinecone.Vector, error) {
+	payload, err := unmarshalPayload(rec.Payload.After)
+	if err != nil {
+		return nil, err
+	}
+
+	id, originalID, err := p.vectorID(rec)
+	if err != nil {
+		return nil, err
+	}
+	return p.buildVector(ctx, rec, payload, payload, id, originalID)
+}
+
+// buildVector builds the vector with the given ID out of the object holding
+// the vector fields, which is either the payload or one of its chunks. The
+// original ID is the one the ID policy replaced, empty if it wasn't.
+func (p *vectorParser) buildVector(
+	ctx context.Context, rec opencdc.Record, payload, obj any, id, originalID string,
+) (*pinecone.Vector, error) {
+	values, sparse, err := p.vectorValues(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	structMap := make(map[string]any)
+	for key, value := range rec.Metadata {
+		structMap[key] = value
+	}
+	// only the record metadata is filtered, the payload fields and the
+	// metadata object are chosen explicitly
+	if p.metadataFilter != nil {
+		p.metadataFilter.apply(structMap)
+	}
+
+	if p.payloadFields != nil {
+		fields, err := p.payloadFields.parse(payload)
+		if err != nil {
+			return nil, err
+		}
+		maps.Copy(structMap, fields)
+	}
+
+	if p.metadata != nil {
+		objMetadata, err := p.object(obj, p.metadata)
+		if err != nil {
+			return nil, err
+		}
+		for key, value := range objMetadata {
+			if value != nil {
+				structMap[key] = metadataValue(value)
+			}
+		}
+	}
+
+	if p.metadataCoercer != nil {
+		if err := p.metadataCoercer.apply(structMap); err != nil {
+			return nil, err
+		}
+	}
+
+	if originalID != "" && p.idPolicy.originalKeyField != "" {
+		structMap[p.idPolicy.originalKeyField] = originalID
+	}
+	if err := p.metadataSize.apply(ctx, id, structMap); err != nil {
+		return nil, err
+	}
+
+	metadata, err := structpb.NewStruct(structMap)
+	if err != nil {
+		return nil, fmt.Errorf("error protobuf struct: %w", err)
+	}
+
+	vec := &pinecone.Vector{
+		//revive:disable-next-line
+		Id:           id,
+		Values:       values,
+		SparseValues: sparse,
+		Metadata:     metadata,
+	}
+
+	return vec, nil
+}
+
+// vectorID returns the ID of the vector of the record, used both by upserts
+// and deletes. The original ID is returned too if it was replaced because
+// Pinecone would reject it.
+func (p *vectorParser) vectorID(rec opencdc.Record) (string, string, error) {
+	id, err := p.ids.id(rec)
+	if err != nil {
+		return "", "", err
+	}
+	return p.idPolicy.apply(id)
+}
+
+// vectorIDPrefix returns the vector ID of the record followed by the
+// separator, the prefix of the IDs of its chunks. Only the vector ID of the
+// record is replaced if the chunk IDs could be invalid, so that the chunks of
+// a record keep a common prefix. The original prefix is returned too if it was
+// replaced.
+func (p *vectorParser) vectorIDPrefix(rec opencdc.Record, separator string) (string, string, error) {
+	id, err := p.ids.id(rec)
+	if err != nil {
+		return "", "", err
+	}
+
+	replaced, originalID, err := p.idPolicy.applyPrefix(id, len(separator)+maxChunkPositionBytes)
+	if err != nil {
+		return "", "", err
+	}
+	if originalID != "" {
+		originalID += separator
+	}
+	return replaced + separator, originalID, nil
+}
+
+// checkRequired checks that the vector has the required components.
+func (p *vectorParser) checkRequired(vec *pinecone.Vector) error {
+	hasDense := len(vec.Values) > 0
+	hasSparse := vec.SparseValues != nil
+
+	switch {
+	case (p.required == requiredDense || p.required == requiredBoth) && !hasDense:
+		return fmt.Errorf("vector %q has no dense values in field %q, required by fields.required %q",
+			vec.Id, p.values, p.required)
+	case (p.required == requiredSparse || p.required == requiredBoth) && !hasSparse:
+		return fmt.Errorf("vector %q has no sparse values in fields %q and %q, required by fields.required %q",
+			vec.Id, p.sparseIndices, p.sparseValues, p.required)
+	}
+	return nil
+}
+
+// float32s returns the array of numbers at the path, nil if missing.
+func (p *vectorParser) float32s(payload any, path fieldPath) ([]float32, error) {
+	items, err := p.array(payload, path)
+	if items == nil || err != nil {
+		return nil, err
+	}
+
+	floats := make([]float32, len(items))
+	for i, item := range items {
+		f, ok := item.(float64)
+		if !ok {
+			return nil, fmt.Errorf("field %q: item %d is a %T, expected a number", path, i, item)
+		}
+		floats[i] = float32(f)
+	}
+	return floats, nil
+}
+
+// uint32s returns the array of non-negative integers at the path, nil if
+// missing.
+func (p *vectorParser) uint32s(payload any, path fieldPath) ([]uint32, error) {
+	items, err := p.array(payload, path)
+	if items == nil || err != nil {
+		return nil, err
+	}
+
+	ints := make([]uint32, len(items))
+	for i, item := range items {
+		f, ok := item.(float64)
+		if !ok || f < 0 || f > math.MaxUint32 || f != math.Trunc(f) {
+			return nil, fmt.Errorf("field %q: item %d is %v, expected a non-negative integer", path, i, item)
+		}
+		ints[i] = uint32(f)
+	}
+	return ints, nil
+}
+
+func (p *vectorParser) array(payload any, path fieldPath) ([]any, error) {
+	value, ok := path.get(payload)
+	if !ok || value == nil {
+		return nil, nil
+	}
+
+	items, ok := value.([]any)
+	if !ok {
+		return nil, fmt.Errorf("field %q is a %T, expected an array", path, value)
+	}
+	return items, nil
+}
+
+func (p *vectorParser) object(payload any, path fieldPath) (map[string]any, error) {
+	value, ok := path.get(payload)
+	if !ok || value == nil {
+		return nil, nil //nolint:nilnil // the field is missing
+	}
+
+	obj, ok := value.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("field %q is a %T, expected an object", path, value)
+	}
+	return obj, nil
+}
+
+// fieldPath is the location of a field in the payload, as a list of object
+// keys or array indexes.
+type fieldPath []string
+
+// parseFieldPath parses either a JSON pointer (RFC 6901), if it starts with
+// "/", or a dot separated path.
+func parseFieldPath(path string) (fieldPath, error) {
+	if path == "" {
+		return nil, errors.New("path is empty")
+	}
+
+	if pointer, ok := strings.CutPrefix(path, "/"); ok {
+		segments := strings.Split(pointer, "/")
+		for i, segment := range segments {
+			segments[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(segment)
+		}
+		return segments, nil
+	}
+
+	segments := strings.Split(path, ".")
+	for _, segment := range segments {
+		if segment == "" {
+			return nil, fmt.Errorf("path %q contains an empty segment", path)
+		}
+	}
+	return segments, nil
+}
+
+func (p fieldPath) String() string {
+	return strings.Join(p, ".")
+}
+
+// get returns the value at the path, and whether it was found.
+func (p fieldPath) get(data any) (any, bool) {
+	for _, segment := range p {
+		switch d := data.(type) {
+		case map[string]any:
+			value, ok := d[segment]
+			if !ok {
+				return nil, false
+			}
+			data = value
+		case []any:
+			idx, err := strconv.Atoi(segment)
+			if err != nil || idx < 0 || idx >= len(d) {
+				return nil, false
+			}
+			data = d[idx]
+		default:
+			return nil, false
+		}
+	}
+	return data, true
+}
@@ -0,0 +1,236 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pinecone
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/conduitio/conduit-commons/opencdc"
+	"github.com/matryer/is"
+	"github.com/pinecone-io/go-pinecone/pinecone"
+)
+
+// parse parses the record into a vector like upserts do, for records that
+// don't need their text embedded.
+func (p *vectorParser) parse(ctx context.Context, rec opencdc.Record) (*pinecone.Vector, error) {
+	vec, text, err := p.parseUpsert(ctx, rec)
+	if err != nil {
+		return nil, err
+	}
+	if text != "" {
+		return nil, fmt.Errorf("vector %q has a text to embed", vec.Id)
+	}
+	return vec, nil
+}
+
+func TestVectorParser_FieldMapping(t *testing.T) {
+	cfg := FieldsConfig{
+		Values:        "embedding",
+		SparseIndices: "/sparse/0/idx",
+		SparseValues:  "/sparse/0/val~1ues",
+		Metadata:      "doc",
+	}
+
+	payloads := map[string]opencdc.Data{
+		"raw data": opencdc.RawData(`{
+			"embedding": [1, 2],
+			"sparse": [{"idx": [3, 5], "val/ues": [0.5, 0.25]}],
+			"doc": {"title": "hello", "pages": 3}
+		}`),
+		"structured data": opencdc.StructuredData{
+			"embedding": []float32{1, 2},
+			"sparse": []any{
+				map[string]any{"idx": []int{3, 5}, "val/ues": []float64{0.5, 0.25}},
+			},
+			"doc": map[string]any{"title": "hello", "pages": 3},
+		},
+	}
+
+	for name, payload := range payloads {
+		t.Run(name, func(t *testing.T) {
+			is := is.New(t)
+
+			parser, err := newVectorParser(cfg, MetadataConfig{})
+			is.NoErr(err)
+
+			vec, err := parser.parse(context.Background(), opencdc.Record{
+				Key:      opencdc.RawData("key1"),
+				Metadata: opencdc.Metadata{"prop": "val"},
+				Payload:  opencdc.Change{After: payload},
+			})
+			is.NoErr(err)
+
+			is.Equal(vec.Id, "key1")
+			is.Equal(vec.Values, []float32{1, 2})
+			is.Equal(vec.SparseValues.Indices, []uint32{3, 5})
+			is.Equal(vec.SparseValues.Values, []float32{0.5, 0.25})
+
+			metadata := vec.Metadata.AsMap()
+			is.Equal(metadata["prop"], "val")
+			is.Equal(metadata["title"], "hello")
+			is.Equal(metadata["pages"], float64(3))
+		})
+	}
+}
+
+func TestVectorParser_Errors(t *testing.T) {
+	testCases := []struct {
+		name    string
+		payload string
+	}{
+		{name: "values not an array", payload: `{"values": "1,2"}`},
+		{name: "values not numbers", payload: `{"values": ["1", "2"]}`},
+		{name: "negative sparse index", payload: `{"sparse_values": {"indices": [-1], "values": [1]}}`},
+		{name: "decimal sparse index", payload: `{"sparse_values": {"indices": [1.5], "values": [1]}}`},
+		{name: "sparse values without indices", payload: `{"sparse_values": {"values": [1]}}`},
+		{name: "metadata not an object", payload: `{"values": [1], "meta": [1]}`},
+		{name: "invalid json", payload: `{"values"`},
+	}
+
+	cfg := defaultFieldsConfig
+	cfg.Metadata = "meta"
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			is := is.New(t)
+
+			parser, err := newVectorParser(cfg, MetadataConfig{})
+			is.NoErr(err)
+
+			_, err = parser.parse(context.Background(), opencdc.Record{
+				Key:     opencdc.RawData("key1"),
+				Payload: opencdc.Change{After: opencdc.RawData(tc.payload)},
+			})
+			is.True(err != nil)
+		})
+	}
+}
+
+func TestParseFieldPath(t *testing.T) {
+	testCases := []struct {
+		path    string
+		want    fieldPath
+		wantErr bool
+	}{
+		{path: "values", want: fieldPath{"values"}},
+		{path: "doc.embedding", want: fieldPath{"doc", "embedding"}},
+		{path: "/doc/embedding", want: fieldPath{"doc", "embedding"}},
+		{path: "/a~1b/c~0d", want: fieldPath{"a/b", "c~d"}},
+		{path: "doc..embedding", wantErr: true},
+		{path: "", wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.path, func(t *testing.T) {
+			is := is.New(t)
+
+			got, err := parseFieldPath(tc.path)
+			if tc.wantErr {
+				is.True(err != nil)
+				return
+			}
+			is.NoErr(err)
+			is.Equal(got, tc.want)
+		})
+	}
+}
+
+func TestVectorParser_Components(t *testing.T) {
+	testCases := []struct {
+		name       string
+		payload    string
+		required   string
+		wantDense  bool
+		wantSparse bool
+		wantErr    bool
+	}{
+		{
+			name:       "dense only",
+			payload:    `{"values": [1, 2]}`,
+			wantDense:  true,
+			wantSparse: false,
+		},
+		{
+			name:       "sparse only",
+			payload:    `{"values": [], "sparse_values": {"indices": [3], "values": [0.5]}}`,
+			wantDense:  false,
+			wantSparse: true,
+		},
+		{
+			name:       "empty sparse values",
+			payload:    `{"values": [1, 2], "sparse_values": {"indices": [], "values": []}}`,
+			wantDense:  true,
+			wantSparse: false,
+		},
+		{
+			name:     "dense required",
+			payload:  `{"sparse_values": {"indices": [3], "values": [0.5]}}`,
+			required: requiredDense,
+			wantErr:  true,
+		},
+		{
+			name:     "sparse required",
+			payload:  `{"values": [1, 2]}`,
+			required: requiredSparse,
+			wantErr:  true,
+		},
+		{
+			name:     "both required",
+			payload:  `{"values": [1, 2]}`,
+			required: requiredBoth,
+			wantErr:  true,
+		},
+		{
+			name:       "both present",
+			payload:    `{"values": [1, 2], "sparse_values": {"indices": [3], "values": [0.5]}}`,
+			required:   requiredBoth,
+			wantDense:  true,
+			wantSparse: true,
+		},
+		{
+			name:    "no values",
+			payload: `{"values": []}`,
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			is := is.New(t)
+
+			cfg := defaultFieldsConfig
+			if tc.required != "" {
+				cfg.Required = tc.required
+			}
+			parser, err := newVectorParser(cfg, MetadataConfig{})
+			is.NoErr(err)
+
+			vec, err := parser.parse(context.Background(), opencdc.Record{
+				Key:     opencdc.RawData("key1"),
+				Payload: opencdc.Change{After: opencdc.RawData(tc.payload)},
+			})
+			if tc.wantErr {
+				is.True(err != nil)
+				return
+			}
+			is.NoErr(err)
+
+			is.Equal(vec.Values != nil, tc.wantDense)
+			is.Equal(vec.SparseValues != nil, tc.wantSparse)
+		})
+	}
+}
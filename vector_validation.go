@@ -0,0 +1,266 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pinecone
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+
+	sdk "github.com/conduitio/conduit-connector-sdk"
+	"github.com/pinecone-io/go-pinecone/pinecone"
+)
+
+const (
+	vectorTypeDense  = "dense"
+	vectorTypeSparse = "sparse"
+)
+
+const (
+	metricCosine     = "cosine"
+	metricDotProduct = "dotproduct"
+)
+
+// controlPlaneAPIVersion is the version of the Pinecone API that introduced
+// sparse indexes, and so the vector type of the index descriptions.
+const controlPlaneAPIVersion = "2025-01"
+
+// indexDescription is the description of the index that the vectors are
+// validated against before being written.
+type indexDescription struct {
+	// dimension is the length of the dense vectors, zero for sparse indexes.
+	dimension int
+	// vectorType is either "dense" or "sparse".
+	vectorType string
+	// metric is the similarity metric of the index, empty if unknown.
+	metric string
+}
+
+type describeIndexParams struct {
+	apiKey, host string
+	tls          TLSConfig
+
+	// name is the name of the index in the control plane, empty if the index
+	// is only described by its host.
+	name             string
+	controlPlaneHost string
+}
+
+// describeIndex describes the index by its name if set, and otherwise by its
+// host.
+func describeIndex(ctx context.Context, params describeIndexParams) (*indexDescription, error) {
+	var desc *indexDescription
+	var err error
+	if params.name != "" {
+		desc, err = describeIndexByName(ctx, params)
+	} else {
+		desc, err = describeIndexByHost(ctx, params)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sdk.Logger(ctx).Info().
+		Int("dimension", desc.dimension).
+		Str("vector_type", desc.vectorType).
+		Str("metric", desc.metric).
+		Msg("described pinecone index")
+
+	return desc, nil
+}
+
+// describeIndexByName describes the index with the control plane, which tells
+// its vector type and metric. The Pinecone client doesn't decode the vector
+// type, so the endpoint is called directly.
+func describeIndexByName(ctx context.Context, params describeIndexParams) (*indexDescription, error) {
+	controlPlane, err := parseIndexHost(params.controlPlaneHost)
+	if err != nil {
+		return nil, fmt.Errorf("invalid control plane host: %w", err)
+	}
+	client, err := params.tls.httpClient(controlPlane)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint := controlPlane.url() + "/indexes/" + url.PathEscape(params.name)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create describe index request: %w", err)
+	}
+	req.Header.Set("Api-Key", params.apiKey)
+	req.Header.Set("X-Pinecone-API-Version", controlPlaneAPIVersion)
+
+	resp, err := doHTTP(client, req, "describe index")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var res struct {
+		Host       string `json:"host"`
+		Dimension  int    `json:"dimension"`
+		Metric     string `json:"metric"`
+		VectorType string `json:"vector_type"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return nil, fmt.Errorf("failed to decode index %q description: %w", params.name, err)
+	}
+
+	// the vectors are validated against the index they're written to
+	host, err := parseIndexHost(params.host)
+	if err != nil {
+		return nil, err
+	}
+	described, err := parseIndexHost(res.Host)
+	if err != nil {
+		return nil, fmt.Errorf("invalid host of index %q: %w", params.name, err)
+	}
+	if described.target != host.target {
+		return nil, fmt.Errorf("index %q is at host %s, not at the configured host %s", params.name, described.target, host.target)
+	}
+
+	desc := &indexDescription{
+		dimension:  res.Dimension,
+		vectorType: res.VectorType,
+		metric:     res.Metric,
+	}
+	switch desc.vectorType {
+	case "":
+		// indexes created before sparse indexes are dense
+		desc.vectorType = vectorTypeDense
+	case vectorTypeDense, vectorTypeSparse:
+	default:
+		return nil, fmt.Errorf("index %q has the unsupported vector type %q", params.name, desc.vectorType)
+	}
+
+	return desc, nil
+}
+
+// describeIndexByHost describes the index with the stats of its host, which
+// only tell its dimension. Dense indexes always have one, an index without it
+// is ambiguous and needs to be described by its name instead.
+func describeIndexByHost(ctx context.Context, params describeIndexParams) (*indexDescription, error) {
+	index, err := newIndex(ctx, newIndexParams{
+		apiKey: params.apiKey,
+		host:   params.host,
+		tls:    params.tls,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create index: %w", err)
+	}
+	defer index.Close()
+
+	stats, err := index.DescribeIndexStats(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe index stats: %w", err)
+	}
+	if stats.Dimension == 0 {
+		return nil, errors.New("the index host reports no dimension, so the vector type of the index is unknown: " +
+			"set indexName to describe the index with the control plane")
+	}
+
+	return &indexDescription{
+		dimension:  int(stats.Dimension),
+		vectorType: vectorTypeDense,
+	}, nil
+}
+
+// validateVector checks the vector locally, so that a bad record fails on its
+// own instead of failing the whole request remotely. The checks depending on
+// the index are skipped if the index is nil.
+func validateVector(vec *pinecone.Vector, index *indexDescription) error {
+	if err := validateVectorValues(vec, index); err != nil {
+		return fmt.Errorf("invalid vector %q: %w", vec.Id, err)
+	}
+	return nil
+}
+
+func validateVectorValues(vec *pinecone.Vector, index *indexDescription) error {
+	if i, ok := nonFinite(vec.Values); ok {
+		return fmt.Errorf("value %d is not a finite number", i)
+	}
+
+	var indices []uint32
+	var sparse []float32
+	if vec.SparseValues != nil {
+		indices, sparse = vec.SparseValues.Indices, vec.SparseValues.Values
+	}
+	if len(indices) != len(sparse) {
+		return fmt.Errorf("%d sparse indices don't match %d sparse values", len(indices), len(sparse))
+	}
+	if i, ok := nonFinite(sparse); ok {
+		return fmt.Errorf("sparse value %d is not a finite number", i)
+	}
+	for i := 1; i < len(indices); i++ {
+		switch {
+		case indices[i] == indices[i-1]:
+			return fmt.Errorf("sparse index %d is duplicated", indices[i])
+		case indices[i] < indices[i-1]:
+			return fmt.Errorf("sparse indices are not in ascending order at position %d", i)
+		}
+	}
+
+	if len(vec.Values) == 0 && len(indices) == 0 {
+		return errors.New("vector has no dense or sparse values")
+	}
+	if index == nil {
+		return nil
+	}
+
+	switch index.vectorType {
+	case vectorTypeSparse:
+		if len(vec.Values) > 0 {
+			return errors.New("dense values can't be written to a sparse index")
+		}
+	default:
+		if len(vec.Values) == 0 {
+			return fmt.Errorf("vector has no dense values, required by the dense index of dimension %d", index.dimension)
+		}
+		if len(vec.Values) != index.dimension {
+			return fmt.Errorf("dimension %d doesn't match the index dimension %d", len(vec.Values), index.dimension)
+		}
+		if index.metric == metricCosine && allZero(vec.Values) {
+			return errors.New("dense values are all zero, which the cosine metric can't compare")
+		}
+		if len(indices) > 0 && index.metric != "" && index.metric != metricDotProduct {
+			return fmt.Errorf("sparse values require the dotproduct metric, the index metric is %s", index.metric)
+		}
+	}
+
+	return nil
+}
+
+func allZero(values []float32) bool {
+	for _, v := range values {
+		if v != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// nonFinite returns the position of the first NaN or infinite value.
+func nonFinite(values []float32) (int, bool) {
+	for i, v := range values {
+		if math.IsNaN(float64(v)) || math.IsInf(float64(v), 0) {
+			return i, true
+		}
+	}
+	return 0, false
+}
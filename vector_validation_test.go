@@ -0,0 +1,222 @@
+// Copyright © 2024 Meroxa, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pinecone
+
+import (
+	"context"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/pinecone-io/go-pinecone/pinecone"
+)
+
+func TestValidateVector(t *testing.T) {
+	dense := &indexDescription{dimension: 2, vectorType: vectorTypeDense}
+	sparse := &indexDescription{vectorType: vectorTypeSparse}
+	cosine := &indexDescription{dimension: 2, vectorType: vectorTypeDense, metric: metricCosine}
+	inf := float32(math.Inf(1))
+	nan := float32(math.NaN())
+
+	vector := func(values []float32, indices []uint32, sparseValues []float32) *pinecone.Vector {
+		return &pinecone.Vector{
+			//revive:disable-next-line
+			Id:     "key1",
+			Values: values,
+			SparseValues: &pinecone.SparseValues{
+				Indices: indices,
+				Values:  sparseValues,
+			},
+		}
+	}
+
+	testCases := []struct {
+		name    string
+		vec     *pinecone.Vector
+		index   *indexDescription
+		wantErr string
+	}{
+		{
+			name:  "dense and sparse values",
+			vec:   vector([]float32{1, 2}, []uint32{1, 3}, []float32{0.5, 0.3}),
+			index: dense,
+		},
+		{
+			name:  "sparse values on a sparse index",
+			vec:   vector(nil, []uint32{1, 3}, []float32{0.5, 0.3}),
+			index: sparse,
+		},
+		{
+			name: "any dimension without index",
+			vec:  vector([]float32{1, 2, 3}, nil, nil),
+		},
+		{
+			name:    "wrong dimension",
+			vec:     vector([]float32{1, 2, 3}, nil, nil),
+			index:   dense,
+			wantErr: "dimension 3 doesn't match the index dimension 2",
+		},
+		{
+			name:    "empty dense vector on a dense index",
+			vec:     vector(nil, []uint32{1}, []float32{0.5}),
+			index:   dense,
+			wantErr: "vector has no dense values",
+		},
+		{
+			name:    "dense values on a sparse index",
+			vec:     vector([]float32{1, 2}, []uint32{1}, []float32{0.5}),
+			index:   sparse,
+			wantErr: "dense values can't be written to a sparse index",
+		},
+		{
+			name:    "no values",
+			vec:     vector(nil, nil, nil),
+			wantErr: "vector has no dense or sparse values",
+		},
+		{
+			name:    "infinite value",
+			vec:     vector([]float32{1, inf}, nil, nil),
+			index:   dense,
+			wantErr: "value 1 is not a finite number",
+		},
+		{
+			name:    "NaN sparse value",
+			vec:     vector([]float32{1, 2}, []uint32{1}, []float32{nan}),
+			index:   dense,
+			wantErr: "sparse value 0 is not a finite number",
+		},
+		{
+			name:    "sparse lengths mismatch",
+			vec:     vector([]float32{1, 2}, []uint32{1, 2}, []float32{0.5}),
+			index:   dense,
+			wantErr: "2 sparse indices don't match 1 sparse values",
+		},
+		{
+			name:    "duplicated sparse index",
+			vec:     vector([]float32{1, 2}, []uint32{1, 1}, []float32{0.5, 0.3}),
+			index:   dense,
+			wantErr: "sparse index 1 is duplicated",
+		},
+		{
+			name:    "unordered sparse indices",
+			vec:     vector([]float32{1, 2}, []uint32{3, 1}, []float32{0.5, 0.3}),
+			index:   dense,
+			wantErr: "not in ascending order",
+		},
+		{
+			name:    "sparse values with the cosine metric",
+			vec:     vector([]float32{1, 2}, []uint32{1}, []float32{0.5}),
+			index:   cosine,
+			wantErr: "sparse values require the dotproduct metric, the index metric is cosine",
+		},
+		{
+			name:    "zero dense values with the cosine metric",
+			vec:     vector([]float32{0, 0}, nil, nil),
+			index:   cosine,
+			wantErr: "dense values are all zero",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			is := is.New(t)
+
+			err := validateVector(tc.vec, tc.index)
+			if tc.wantErr == "" {
+				is.NoErr(err)
+				return
+			}
+
+			is.True(err != nil)
+			is.True(strings.Contains(err.Error(), `invalid vector "key1"`))
+			is.True(strings.Contains(err.Error(), tc.wantErr))
+		})
+	}
+}
+
+func TestDescribeIndex(t *testing.T) {
+	ctx := context.Background()
+
+	dense, err := newFakePinecone(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dense.stop()
+	sparse, err := newFakePinecone(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sparse.stop()
+
+	params := func(server *fakePinecone, name string) describeIndexParams {
+		return describeIndexParams{
+			apiKey:           "fake-api-key",
+			host:             server.host(),
+			name:             name,
+			controlPlaneHost: server.controlPlane(t, "index", metricCosine),
+		}
+	}
+
+	t.Run("dense index by host", func(t *testing.T) {
+		is := is.New(t)
+
+		desc, err := describeIndex(ctx, params(dense, ""))
+		is.NoErr(err)
+		is.Equal(*desc, indexDescription{dimension: 2, vectorType: vectorTypeDense})
+	})
+
+	t.Run("index without dimension by host", func(t *testing.T) {
+		is := is.New(t)
+
+		_, err := describeIndex(ctx, params(sparse, ""))
+		is.True(err != nil)
+		is.True(strings.Contains(err.Error(), "set indexName"))
+	})
+
+	t.Run("dense index by name", func(t *testing.T) {
+		is := is.New(t)
+
+		desc, err := describeIndex(ctx, params(dense, "index"))
+		is.NoErr(err)
+		is.Equal(*desc, indexDescription{dimension: 2, vectorType: vectorTypeDense, metric: metricCosine})
+	})
+
+	t.Run("sparse index by name", func(t *testing.T) {
+		is := is.New(t)
+
+		desc, err := describeIndex(ctx, params(sparse, "index"))
+		is.NoErr(err)
+		is.Equal(*desc, indexDescription{vectorType: vectorTypeSparse, metric: metricCosine})
+	})
+
+	t.Run("unknown name", func(t *testing.T) {
+		is := is.New(t)
+
+		_, err := describeIndex(ctx, params(dense, "other"))
+		is.True(err != nil)
+		is.True(strings.Contains(err.Error(), "status 404"))
+	})
+
+	t.Run("index at another host", func(t *testing.T) {
+		is := is.New(t)
+
+		p := params(dense, "index")
+		p.controlPlaneHost = sparse.controlPlane(t, "index", metricCosine)
+		_, err := describeIndex(ctx, p)
+		is.True(err != nil)
+		is.True(strings.Contains(err.Error(), "not at the configured host"))
+	})
+}